@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/makalin/upd8/internal/upd8"
+)
+
+// listenAPI opens the --listen address: "unix:/path/to.sock" for a unix
+// socket only the current user can connect to, otherwise a host:port that
+// must be a loopback address.
+func listenAPI(addr string) (ln net.Listener, tcp bool, err error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if st, err := os.Lstat(path); err == nil && st.Mode()&os.ModeSocket != 0 {
+			if conn, err := net.Dial("unix", path); err == nil {
+				conn.Close()
+				return nil, false, fmt.Errorf("--listen %s: another daemon is already listening", addr)
+			}
+			// Left behind by a daemon that did not shut down cleanly.
+			os.Remove(path)
+		}
+		ln, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, false, err
+		}
+		if err := os.Chmod(path, 0o600); err != nil {
+			ln.Close()
+			return nil, false, err
+		}
+		return ln, false, nil
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, false, fmt.Errorf("--listen %s: want unix:/path or host:port", addr)
+	}
+	ip := net.ParseIP(host)
+	if host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, false, fmt.Errorf("--listen %s: only loopback addresses (127.0.0.1, ::1, localhost) are allowed", addr)
+	}
+	ln, err = net.Listen("tcp", addr)
+	return ln, true, err
+}
+
+// invalidatingUpgrade drops the cached result of the upgraded manager once
+// upgrade returns, whether or not it succeeded, so --cached never shows the
+// packages as outdated on the strength of a scan from before the upgrade.
+func invalidatingUpgrade(upgrade func(context.Context, string, []string) error, cache upd8.ResultCache, cfg upd8.CacheConfig) func(context.Context, string, []string) error {
+	return func(ctx context.Context, key string, pkgs []string) error {
+		err := upgrade(ctx, key, pkgs)
+		if !cfg.Disabled {
+			if cerr := cache.Invalidate(key); cerr != nil {
+				fmt.Fprintf(os.Stderr, "warning: %v\n", cerr)
+			}
+		}
+		return err
+	}
+}
+
+// serveAPI serves api on ln until ctx is cancelled.
+func serveAPI(ctx context.Context, ln net.Listener, tcp bool, api *upd8.APIServer) {
+	handler := api.Handler()
+	if tcp {
+		handler = upd8.LoopbackOnly(handler)
+	}
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "api: %v\n", err)
+	}
+}
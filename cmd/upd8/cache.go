@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/makalin/upd8/internal/upd8"
+)
+
+// refreshLockTTL bounds how often --cached may spawn a background refresh.
+// A refresh that crashes leaves its lock behind; it simply expires.
+const refreshLockTTL = 10 * time.Minute
+
+// loadCached returns every cached result for the given targets and whether
+// any of them is stale.
+func loadCached(cache upd8.ResultCache, targets []target, now time.Time) ([]upd8.Result, bool, bool) {
+	wanted := map[string]bool{}
+	for _, t := range targets {
+		wanted[t.host.Name] = true
+	}
+
+	stale := false
+	var results []upd8.Result
+	for _, entry := range cache.All() {
+		if !wanted[entry.Result.Host] {
+			continue
+		}
+		if !cache.Fresh(entry, now) {
+			stale = true
+		}
+		results = append(results, entry.ToResult())
+	}
+	if len(results) == 0 {
+		return nil, false, false
+	}
+	return results, stale, true
+}
+
+// fillOffline fills in managers skipped by an offline scan from the cache;
+// see ResultCache.FillOffline.
+func fillOffline(cache upd8.ResultCache, cfg upd8.CacheConfig, results []upd8.Result) []upd8.Result {
+	if cfg.Disabled {
+		return results
+	}
+	return cache.FillOffline(results)
+}
+
+// cacheResults stores the results of a completed live scan.
+func cacheResults(ctx context.Context, cache upd8.ResultCache, cfg upd8.CacheConfig, start time.Time, results []upd8.Result) {
+	if cfg.Disabled || ctx.Err() != nil {
+		return
+	}
+	if err := cache.Store(results, start); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not update result cache: %v\n", err)
+	}
+}
+
+// refreshInBackground starts a detached live scan that rewrites the cache.
+// The lock file keeps repeated prompt renders from piling up scans. extraArgs
+// select the same targets as the current command line; see targetArgs.
+func refreshInBackground(cache upd8.ResultCache, configPath string, extraArgs []string) {
+	lock := filepath.Join(cache.Dir, "refresh.lock")
+	if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) < refreshLockTTL {
+		return
+	}
+	if err := os.MkdirAll(cache.Dir, 0o755); err != nil {
+		return
+	}
+	if err := os.WriteFile(lock, []byte(time.Now().Format(time.RFC3339)), 0o644); err != nil {
+		return
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+
+	args := append([]string{"--config", configPath, "--no-color", "--format", "json"}, extraArgs...)
+	cmd := exec.Command(exe, args...)
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "warning: could not refresh cache in background: %v\n", err)
+		}
+		_ = os.Remove(lock)
+		return
+	}
+	_ = cmd.Process.Release()
+}
+
+// runCache implements `upd8 cache clear [key...]`, which drops cached results
+// so the next --cached call has to wait for a live scan. Run it after
+// upgrading packages outside upd8.
+func runCache(args []string) int {
+	if len(args) == 0 || args[0] != "clear" {
+		fmt.Fprintln(os.Stderr, "usage: upd8 cache clear [manager...]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("upd8 cache clear", flag.ContinueOnError)
+	configPath := fs.String("config", upd8.DefaultConfigPath(), "Path to the JSON config file")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	cfg, err := upd8.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if err := upd8.NewResultCache(cfg.Cache).Invalidate(fs.Args()...); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
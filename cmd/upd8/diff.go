@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/makalin/upd8/internal/upd8"
+)
+
+// runDiff implements `upd8 diff [<scanA> [<scanB>]]`.
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("upd8 diff", flag.ContinueOnError)
+	noColor := fs.Bool("no-color", false, "Disable ANSI colors in the output")
+	configPath := fs.String("config", upd8.DefaultConfigPath(), "Path to the JSON config file")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 2 {
+		fmt.Fprintln(os.Stderr, "usage: upd8 diff [<scanA> [<scanB>]]")
+		return 2
+	}
+
+	cfg, err := upd8.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	store := upd8.NewHistoryStore(cfg.History)
+
+	ids, err := store.IDs()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// Missing arguments default to the two most recent scans.
+	var fromID, toID string
+	switch fs.NArg() {
+	case 0:
+		if len(ids) < 2 {
+			fmt.Fprintln(os.Stderr, "need at least two recorded scans to diff")
+			return 1
+		}
+		fromID, toID = ids[len(ids)-2], ids[len(ids)-1]
+	case 1:
+		if len(ids) == 0 {
+			fmt.Fprintln(os.Stderr, "no scans recorded yet")
+			return 1
+		}
+		fromID, toID = fs.Arg(0), ids[len(ids)-1]
+	default:
+		fromID, toID = fs.Arg(0), fs.Arg(1)
+	}
+
+	from, err := store.Load(fromID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	to, err := store.Load(toID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// Managers that failed in the older scan are compared as of their last
+	// successful check before it.
+	if scans, err := store.List(); err == nil {
+		for i, rec := range scans {
+			if rec.ID == from.ID {
+				from = upd8.Baseline(scans[:i+1])
+				break
+			}
+		}
+	}
+
+	fmt.Fprintf(os.Stdout, "Comparing %s → %s\n\n", from.ID, to.ID)
+	renderer := upd8.Renderer{Writer: os.Stdout, EnableColor: !*noColor}
+	renderer.RenderDiff(upd8.DiffScans(from, to))
+	return 0
+}
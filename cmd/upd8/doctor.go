@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/makalin/upd8/internal/upd8"
+)
+
+// runDoctor implements `upd8 doctor`, which reports how every supported
+// manager was detected (or why it was not) along with upd8's own paths.
+func runDoctor(args []string) int {
+	fs := flag.NewFlagSet("upd8 doctor", flag.ContinueOnError)
+	noColor := fs.Bool("no-color", false, "Disable ANSI colors in the output")
+	format := fs.String("format", "table", "Output format: table or json")
+	configPath := fs.String("config", upd8.DefaultConfigPath(), "Path to the JSON config file")
+	hostName := fs.String("host", localHost, "Inspect this ssh destination (e.g. user@box) instead of the local machine")
+	container := fs.String("container", "", "Inspect this running container instead of the local machine")
+	containerEngine := fs.String("container-engine", "", "Container CLI to use: docker or podman (default: whichever is installed)")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := upd8.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	// Commands run in their own process groups, so Ctrl+C has to cancel them
+	// rather than just end upd8.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	cfg.Hosts, cfg.Containers = []string{*hostName}, nil
+	if *container != "" {
+		cfg.Hosts, cfg.Containers = nil, []string{*container}
+	}
+	if *containerEngine != "" {
+		cfg.ContainerEngine = *containerEngine
+	}
+	targets, err := scanTargets(ctx, cfg, false)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	t := targets[0]
+	host := t.host
+	if t.connect != nil {
+		if err := t.connect(ctx); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			if hint := upd8.ErrorHint(err); hint != "" {
+				fmt.Fprintln(os.Stderr, "hint:", hint)
+			}
+			return 1
+		}
+	}
+	scanner := upd8.Scanner{Runner: host.Runner, Managers: upd8.DefaultManagers(host, cfg)}
+	detections := scanner.Detect(ctx)
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(detections); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	configState := "not found, using defaults"
+	if _, err := os.Stat(*configPath); err == nil {
+		configState = "loaded"
+	}
+	if host.Name != "" {
+		fmt.Fprintf(os.Stdout, "Host:    %s\n", host.Name)
+	}
+	fmt.Fprintf(os.Stdout, "Config:  %s (%s)\n", *configPath, configState)
+	fmt.Fprintf(os.Stdout, "History: %s\n", upd8.NewHistoryStore(cfg.History).Dir)
+	fmt.Fprintf(os.Stdout, "Cache:   %s\n\n", upd8.NewResultCache(cfg.Cache).Dir)
+
+	renderer := upd8.Renderer{Writer: os.Stdout, EnableColor: !*noColor}
+	renderer.RenderDetections(detections)
+	return 0
+}
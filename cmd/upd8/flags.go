@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/makalin/upd8/internal/upd8"
+)
+
+// durationMapFlag collects repeated name=duration flags, e.g. --manager-timeout flatpak=3m.
+type durationMapFlag map[string]upd8.Duration
+
+func (f durationMapFlag) String() string {
+	parts := make([]string, 0, len(f))
+	for name, d := range f {
+		parts = append(parts, name+"="+d.String())
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (f durationMapFlag) Set(value string) error {
+	name, raw, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=duration, got %q", value)
+	}
+	d, err := upd8.ParseDuration(raw)
+	if err != nil {
+		return err
+	}
+	f[name] = upd8.Duration(d)
+	return nil
+}
+
+// stringsFlag collects the values of a repeatable flag, e.g. --host a --host b.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
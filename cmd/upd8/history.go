@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/makalin/upd8/internal/upd8"
+)
+
+// runHistory implements `upd8 history` and `upd8 history show <id>`.
+func runHistory(args []string) int {
+	if len(args) > 0 && args[0] == "show" {
+		return runHistoryShow(args[1:])
+	}
+
+	fs := flag.NewFlagSet("upd8 history", flag.ContinueOnError)
+	limit := fs.Int("limit", 20, "Number of most recent scans to list (0 for all)")
+	noColor := fs.Bool("no-color", false, "Disable ANSI colors in the output")
+	configPath := fs.String("config", upd8.DefaultConfigPath(), "Path to the JSON config file")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := upd8.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	records, err := upd8.NewHistoryStore(cfg.History).List()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// Newest first reads better for a history listing.
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	if *limit > 0 && len(records) > *limit {
+		records = records[:*limit]
+	}
+
+	renderer := upd8.Renderer{Writer: os.Stdout, EnableColor: !*noColor}
+	renderer.RenderHistory(records)
+	return 0
+}
+
+func runHistoryShow(args []string) int {
+	fs := flag.NewFlagSet("upd8 history show", flag.ContinueOnError)
+	showPackages := fs.Bool("packages", false, "Render a short list of outdated packages per manager")
+	noColor := fs.Bool("no-color", false, "Disable ANSI colors in the output")
+	configPath := fs.String("config", upd8.DefaultConfigPath(), "Path to the JSON config file")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: upd8 history show <id>")
+		return 2
+	}
+
+	cfg, err := upd8.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	rec, err := upd8.NewHistoryStore(cfg.History).Load(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stdout, "Scan %s on %s at %s\n\n", rec.ID, rec.Hostname, rec.Time.Local().Format("2006-01-02 15:04:05"))
+
+	results := make([]upd8.Result, 0, len(rec.Results))
+	for _, r := range rec.Results {
+		results = append(results, r.Result())
+	}
+
+	renderer := upd8.Renderer{
+		Writer:       os.Stdout,
+		EnableColor:  !*noColor,
+		ShowPackages: *showPackages,
+		EmptyMessage: "No package managers were detected in this scan.",
+		Now:          rec.Time,
+	}
+	renderer.Render(results)
+	return 0
+}
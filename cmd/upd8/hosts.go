@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/makalin/upd8/internal/upd8"
+)
+
+// localHost is the --host value naming the machine upd8 runs on.
+const localHost = "local"
+
+// target is one machine or container to scan.
+type target struct {
+	host upd8.Host
+	// connect checks that a remote target is reachable; nil for this machine.
+	connect   func(context.Context) error
+	transport string
+	// managers replaces the default managers, e.g. for a --root image.
+	managers []upd8.Manager
+}
+
+// rootTarget returns the filesystem tree at root as a target whose managers
+// only read its package databases.
+func rootTarget(cfg upd8.Config, root string) (target, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return target{}, err
+	}
+	if st, err := os.Stat(abs); err != nil || !st.IsDir() {
+		return target{}, fmt.Errorf("--root %s: not a directory", root)
+	}
+	return target{host: upd8.Host{Name: abs}, managers: upd8.RootManagers(abs, cfg)}, nil
+}
+
+// scanTargets resolves the configured hosts and containers. Nothing
+// configured means only this machine.
+func scanTargets(ctx context.Context, cfg upd8.Config, allContainers bool) ([]target, error) {
+	var targets []target
+	for _, name := range cfg.Hosts {
+		targets = append(targets, openHost(ctx, cfg, name))
+	}
+
+	containers := cfg.Containers
+	if len(containers) > 0 || allContainers {
+		engine := cfg.ContainerEngine
+		if engine == "" {
+			engine = upd8.DetectContainerEngine()
+		}
+		if engine == "" {
+			return nil, fmt.Errorf("no container engine found (install docker or podman, or set container_engine)")
+		}
+		if allContainers {
+			running, err := upd8.ListContainers(ctx, engine, cfg.Scan)
+			if err != nil {
+				return nil, err
+			}
+			containers = append(containers, running...)
+		}
+		seen := map[string]bool{}
+		for _, name := range containers {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			runner := upd8.NewContainerRunner(engine, name, cfg.Scan)
+			targets = append(targets, target{
+				// Prefixed with the engine so that results cannot be mixed up
+				// with those of an ssh host of the same name.
+				host:      upd8.Host{Name: engine + ":" + name, Runner: runner, Probe: upd8.NewShellProber(ctx, runner)},
+				connect:   runner.Ping,
+				transport: engine,
+			})
+		}
+	}
+
+	if len(targets) == 0 && !allContainers {
+		targets = append(targets, openHost(ctx, cfg, localHost))
+	}
+	return targets, nil
+}
+
+// hostScanners returns one copy of base per target, each with that target's
+// managers.
+func hostScanners(cfg upd8.Config, targets []target, base upd8.Scanner) []upd8.Scanner {
+	scanners := make([]upd8.Scanner, 0, len(targets))
+	for _, t := range targets {
+		s := base
+		s.Runner = t.host.Runner
+		s.Managers = t.managers
+		if s.Managers == nil {
+			s.Managers = upd8.DefaultManagers(t.host, cfg)
+			// As root a permission error has some other cause. Whether the
+			// host is root is only looked up once one happens.
+			host := t.host
+			s.NeedsRoot = func(name string) bool {
+				return cfg.Scan.NeedsRoot(name) && !host.IsRoot()
+			}
+		}
+		s.Host = t.host.Name
+		s.Connect = t.connect
+		s.Transport = t.transport
+		scanners = append(scanners, s)
+	}
+	return scanners
+}
+
+// openHost returns the machine behind a --host value: this one for "local",
+// otherwise an ssh destination whose lookups stop once ctx is cancelled.
+func openHost(ctx context.Context, cfg upd8.Config, name string) target {
+	if name == "" || name == localHost {
+		return target{host: upd8.LocalHost(upd8.NewExecRunner(cfg.Scan))}
+	}
+	runner := upd8.NewSSHRunner(name, cfg.Scan)
+	return target{
+		host:      upd8.Host{Name: name, Runner: runner, Probe: upd8.NewShellProber(ctx, runner)},
+		connect:   runner.Ping,
+		transport: "ssh",
+	}
+}
+
+// targetArgs turns the target flags back into arguments for a child scan.
+func targetArgs(hosts, containers []string, allContainers bool, engine string) []string {
+	var args []string
+	if engine != "" {
+		args = append(args, "--container-engine", engine)
+	}
+	for _, host := range hosts {
+		args = append(args, "--host", host)
+	}
+	for _, name := range containers {
+		args = append(args, "--container", name)
+	}
+	if allContainers {
+		args = append(args, "--all-containers")
+	}
+	return args
+}
+
+// prepareSudo sets up elevation for root-only managers on every target. A
+// target where that fails still gets scanned, with those managers skipped.
+// With prompt set the user may be asked for a password on a terminal.
+func prepareSudo(ctx context.Context, cfg upd8.Config, targets []target, prompt bool) {
+	if cfg.Scan.Sudo == "" {
+		return
+	}
+	interactive := prompt && upd8.IsTerminal(os.Stdin) && upd8.IsTerminal(os.Stderr)
+	for i := range targets {
+		host, err := upd8.PrepareSudo(ctx, targets[i].host, cfg.Scan.Sudo, interactive)
+		if err != nil {
+			name := host.Name
+			if name == "" {
+				name = "this machine"
+			}
+			fmt.Fprintf(os.Stderr, "warning: %s: cannot elevate root-only managers, skipping them: %v\n", name, err)
+			continue
+		}
+		targets[i].host = host
+	}
+}
+
+// resolvedConfig returns cfg with the targets actually scanned, so that
+// --all-containers and engine detection are not repeated on replay.
+func resolvedConfig(cfg upd8.Config, targets []target) upd8.Config {
+	cfg.Hosts, cfg.Containers = nil, nil
+	for _, t := range targets {
+		switch t.transport {
+		case "", "ssh":
+			name := t.host.Name
+			if name == "" {
+				name = localHost
+			}
+			cfg.Hosts = append(cfg.Hosts, name)
+		default:
+			cfg.Containers = append(cfg.Containers, strings.TrimPrefix(t.host.Name, t.transport+":"))
+			cfg.ContainerEngine = t.transport
+		}
+	}
+	return cfg
+}
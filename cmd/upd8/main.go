@@ -11,22 +11,217 @@ import (
 	"github.com/makalin/upd8/internal/upd8"
 )
 
+// ageHistoryDepth bounds how many past scans are read to compute outdated ages.
+// Saved scans carry their ages forward, so only the most recent few matter.
+const ageHistoryDepth = 50
+
 func main() {
 	os.Exit(run(os.Args[1:]))
 }
 
 func run(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "scan":
+			return runScan(args[1:])
+		case "history":
+			return runHistory(args[1:])
+		case "diff":
+			return runDiff(args[1:])
+		case "doctor":
+			return runDoctor(args[1:])
+		case "cache":
+			return runCache(args[1:])
+		}
+	}
+	return runScan(args)
+}
+
+func runScan(args []string) int {
 	fs := flag.NewFlagSet("upd8", flag.ContinueOnError)
 	watch := fs.Bool("watch", false, "Run in daemon mode, printing summaries at each interval")
 	interval := fs.Duration("interval", 24*time.Hour, "Scan interval when running with --watch")
+	schedule := fs.String("schedule", "", "Watch on a cron schedule instead of an interval (e.g. \"0 9 * * MON-FRI\"); implies --watch")
+	timezone := fs.String("timezone", "", "Time zone for --schedule (e.g. Europe/Berlin; default: local)")
+	var jitter upd8.Duration
+	fs.Var(&jitter, "jitter", "Delay each scheduled scan by a random amount up to this (e.g. 10m)")
 	showPackages := fs.Bool("packages", false, "Render a short list of outdated packages per manager")
 	noColor := fs.Bool("no-color", false, "Disable ANSI colors in the output")
 	verbose := fs.Bool("verbose", false, "Include managers even when no updates are found")
+	configPath := fs.String("config", upd8.DefaultConfigPath(), "Path to the JSON config file")
+	noHistory := fs.Bool("no-history", false, "Do not record this scan in the history store")
+	changesOnly := fs.Bool("changes-only", false, "With --watch, only print when something changed since the previous scan")
+	var summaryEvery upd8.Duration
+	fs.Var(&summaryEvery, "summary-every", "With --watch --changes-only, also print the full report this often (e.g. 7d)")
+	format := fs.String("format", "table", "Output format: table, json or prometheus")
+	var maxAge upd8.Duration
+	fs.Var(&maxAge, "max-age", "Exit with status 3 when a package has been outdated longer than this (e.g. 30d)")
+	cached := fs.Bool("cached", false, "Serve results from the cache instantly, refreshing it in the background when stale")
+	var cacheTTL upd8.Duration
+	fs.Var(&cacheTTL, "cache-ttl", "How long cached results stay fresh (default 1h)")
+	maxConcurrency := fs.Int("max-concurrency", -1, "Maximum number of managers checked at once (0 for no limit)")
+	var cmdTimeout upd8.Duration
+	fs.Var(&cmdTimeout, "timeout", "Timeout for each package manager command (default 60s)")
+	noProgress := fs.Bool("no-progress", false, "Do not show live scan progress on stderr")
+	managerTimeouts := durationMapFlag{}
+	fs.Var(managerTimeouts, "manager-timeout", "Per-manager check timeout as name=duration; may be repeated (e.g. flatpak=3m)")
+	retries := fs.Int("retries", -1, "Retry checks failing with network or timeout errors this many times (default 2)")
+	offline := fs.Bool("offline", false, "Use only local data; managers that need the network are skipped or served from the cache")
+	refresh := fs.Bool("refresh", false, "Refresh package metadata (e.g. brew update) before checking")
+	var retryBackoff upd8.Duration
+	fs.Var(&retryBackoff, "retry-backoff", "Delay before the first retry, doubled after each (default 2s)")
+	var hosts stringsFlag
+	fs.Var(&hosts, "host", "Scan this ssh destination (e.g. user@box) instead; may be repeated, \"local\" is this machine")
+	var containers stringsFlag
+	fs.Var(&containers, "container", "Scan inside this running container; may be repeated")
+	allContainers := fs.Bool("all-containers", false, "Scan inside every running container")
+	record := fs.String("record", "", "Save every command and lookup of this scan to a bundle file for bug reports")
+	replay := fs.String("replay", "", "Reproduce a scan from a bundle written by --record without running anything")
+	sudo := fs.Bool("sudo", false, "Run managers listed in scan.requires_root through sudo or doas, and retry snap and flatpak that way when refused permission")
+	containerEngine := fs.String("container-engine", "", "Container CLI to use: docker or podman (default: whichever is installed)")
+	listen := fs.String("listen", "", "With --watch, serve results over HTTP on unix:/path/to.sock or a loopback host:port")
+	allowApply := fs.Bool("allow-apply", false, "With --listen, let the dashboard and API upgrade selected outdated packages")
+	root := fs.String("root", "", "Inspect the packages installed in the filesystem tree at this path without running anything in it")
 
 	if err := fs.Parse(args); err != nil {
 		return 2
 	}
 
+	var cron *upd8.Cron
+	if *schedule != "" {
+		intervalSet := false
+		fs.Visit(func(f *flag.Flag) { intervalSet = intervalSet || f.Name == "interval" })
+		if intervalSet {
+			fmt.Fprintln(os.Stderr, "--schedule cannot be combined with --interval")
+			return 2
+		}
+		var loc *time.Location
+		if *timezone != "" {
+			var err error
+			if loc, err = time.LoadLocation(*timezone); err != nil {
+				fmt.Fprintf(os.Stderr, "unknown --timezone %q\n", *timezone)
+				return 2
+			}
+		}
+		var err error
+		if cron, err = upd8.ParseCron(*schedule, loc); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		*watch = true
+	} else if *timezone != "" || jitter > 0 {
+		fmt.Fprintln(os.Stderr, "--timezone and --jitter require --schedule")
+		return 2
+	}
+
+	if *listen != "" && !*watch {
+		fmt.Fprintln(os.Stderr, "--listen requires --watch or --schedule")
+		return 2
+	}
+	if *allowApply && *listen == "" {
+		fmt.Fprintln(os.Stderr, "--allow-apply requires --listen")
+		return 2
+	}
+
+	cfg, err := upd8.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if maxAge > 0 {
+		cfg.Policy.MaxAge = maxAge
+	}
+	switch *format {
+	case "table", "json", "prometheus":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown --format %q (want table, json or prometheus)\n", *format)
+		return 2
+	}
+	if summaryEvery > 0 && !(*watch && *changesOnly) {
+		fmt.Fprintln(os.Stderr, "--summary-every requires --watch and --changes-only")
+		return 2
+	}
+	if *offline && *refresh {
+		fmt.Fprintln(os.Stderr, "--refresh cannot be combined with --offline")
+		return 2
+	}
+	if (*record != "" || *replay != "") && (*watch || *cached) {
+		fmt.Fprintln(os.Stderr, "--record and --replay cannot be combined with --watch or --cached")
+		return 2
+	}
+	if *record != "" && *replay != "" {
+		fmt.Fprintln(os.Stderr, "--record cannot be combined with --replay")
+		return 2
+	}
+	if *root != "" && (len(hosts) > 0 || len(containers) > 0 || *allContainers || *sudo || *record != "" || *replay != "" || *watch || *cached) {
+		fmt.Fprintln(os.Stderr, "--root cannot be combined with --host, --container, --all-containers, --sudo, --record, --replay, --watch or --cached")
+		return 2
+	}
+	if cacheTTL > 0 {
+		cfg.Cache.TTL = cacheTTL
+	}
+	if *maxConcurrency >= 0 {
+		cfg.Scan.MaxConcurrency = *maxConcurrency
+	}
+	if cmdTimeout > 0 {
+		cfg.Scan.Timeout = cmdTimeout
+	}
+	for name, d := range managerTimeouts {
+		if cfg.Scan.Timeouts == nil {
+			cfg.Scan.Timeouts = map[string]upd8.Duration{}
+		}
+		cfg.Scan.Timeouts[name] = d
+	}
+	if *retries >= 0 {
+		cfg.Scan.Retries = *retries
+	}
+	if retryBackoff > 0 {
+		cfg.Scan.RetryBackoff = retryBackoff
+	}
+	if len(hosts) > 0 {
+		cfg.Hosts = hosts
+	}
+	if len(containers) > 0 {
+		cfg.Containers = containers
+	}
+	if *containerEngine != "" {
+		cfg.ContainerEngine = *containerEngine
+	}
+	if *sudo && cfg.Scan.Sudo == "" {
+		cfg.Scan.Sudo = "auto"
+	}
+	var replayer *upd8.Replayer
+	if *replay != "" {
+		bundle, err := upd8.LoadRecording(*replay)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		// Scan exactly as recorded, and leave this machine's history and
+		// cache alone.
+		cfg = bundle.Config
+		cfg.History.Disabled = true
+		cfg.Cache.Disabled = true
+		cfg.Policy.MaxAge = maxAge
+		*offline, *refresh, *allContainers = bundle.Offline, bundle.Refresh, false
+		replayer = upd8.NewReplayer(bundle)
+	}
+	if *root != "" {
+		// An image is not this machine: keep it out of the history and cache.
+		cfg.History.Disabled = true
+		cfg.Cache.Disabled = true
+		cfg.Policy.MaxAge = maxAge
+		cfg.Scan.Sudo = ""
+	}
+	// Package ages come from earlier scans; without them every package would
+	// pass the max-age policy unchecked.
+	if cfg.History.Disabled && cfg.Policy.MaxAge > 0 {
+		fmt.Fprintln(os.Stderr, "--max-age and policy.max_age need the scan history, which is disabled here")
+		return 2
+	}
+	store := upd8.NewHistoryStore(cfg.History)
+	cache := upd8.NewResultCache(cfg.Cache)
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -38,13 +233,61 @@ func run(args []string) int {
 		cancel()
 	}()
 
-	runner := upd8.ExecRunner{Timeout: 60 * time.Second}
-	scanner := upd8.Scanner{Runner: runner, Managers: upd8.DefaultManagers(runner)}
+	var targets []target
+	if *root != "" {
+		t, err := rootTarget(cfg, *root)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		targets = []target{t}
+	} else {
+		targets, err = scanTargets(ctx, cfg, *allContainers)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	var recorder *upd8.Recorder
+	switch {
+	case *record != "":
+		recorder = upd8.NewRecorder()
+		for i := range targets {
+			t := &targets[i]
+			t.host = recorder.Host(t.host)
+			if t.connect != nil {
+				t.connect = recorder.Connect(t.host.Name, t.connect)
+			}
+		}
+	case replayer != nil:
+		for i := range targets {
+			t := &targets[i]
+			t.host = replayer.Host(t.host)
+			if t.connect != nil {
+				t.connect = replayer.Connect(t.host.Name)
+			}
+		}
+	}
+	prepareSudo(ctx, cfg, targets, replayer == nil)
+	scanner := upd8.MultiScanner{Scanners: hostScanners(cfg, targets, upd8.Scanner{
+		MaxConcurrency:  cfg.Scan.MaxConcurrency,
+		Timeouts:        cfg.Scan.ManagerTimeouts(),
+		Retries:         cfg.Scan.Retries,
+		RetryBackoff:    time.Duration(cfg.Scan.RetryBackoff),
+		Refresh:         *refresh,
+		RefreshManagers: cfg.Scan.Refresh,
+		RefreshTimeout:  time.Duration(cfg.Scan.RefreshTimeout),
+		Offline:         *offline,
+	})}
+	if !*noProgress && upd8.IsTerminal(os.Stderr) {
+		scanner.Progress = upd8.NewProgressDisplay(os.Stderr, !*noColor)
+	}
 
 	renderer := upd8.Renderer{
-		Writer:       os.Stdout,
-		EnableColor:  !*noColor,
-		ShowPackages: *showPackages,
+		Writer:        os.Stdout,
+		EnableColor:   !*noColor,
+		ShowPackages:  *showPackages,
+		ShowDetection: *verbose,
 	}
 
 	if *verbose {
@@ -53,38 +296,203 @@ func run(args []string) int {
 		renderer.EmptyMessage = "No updates found. (Use --verbose to show all managers.)"
 	}
 
+	if *cached && !*watch && !cfg.Cache.Disabled {
+		now := time.Now()
+		if results, stale, ok := loadCached(cache, targets, now); ok {
+			if stale {
+				refreshInBackground(cache, *configPath, targetArgs(hosts, containers, *allContainers, *containerEngine))
+			}
+			printResults(*format, renderer, *verbose, store, upd8.NewScanRecord(now, results), results)
+			return exitCode(results, cfg.Policy, now)
+		}
+		// Nothing cached yet, so fall through to a live scan that fills the cache.
+	}
+
 	if *watch {
 		if *interval <= 0 {
 			fmt.Fprintln(os.Stderr, "interval must be positive when using --watch")
 			return 2
 		}
 
+		var api *upd8.APIServer
+		apiDone := make(chan struct{})
+		if *listen == "" {
+			close(apiDone)
+		} else {
+			ln, tcp, err := listenAPI(*listen)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 2
+			}
+			api = upd8.NewAPIServer(store)
+			if *allowApply {
+				api.Upgrade = invalidatingUpgrade(scanner.Upgrade, cache, cfg.Cache)
+			}
+			scanner.Rescan = api.Rescan()
+			go func() {
+				defer close(apiDone)
+				serveAPI(ctx, ln, tcp, api)
+			}()
+			if tcp {
+				fmt.Fprintf(os.Stderr, "Serving the API and dashboard on http://%s/\n", ln.Addr())
+			} else {
+				fmt.Fprintf(os.Stderr, "Serving the API and dashboard on %s\n", ln.Addr())
+			}
+		}
+
 		renderer.Timestamp = true
-		fmt.Fprintf(os.Stdout, "Watching for updates every %s. Press Ctrl+C to stop.\n", (*interval).Truncate(time.Second))
+		if cron != nil {
+			next := cron.Next(time.Now())
+			if next.IsZero() {
+				fmt.Fprintf(os.Stderr, "schedule %q never runs\n", cron)
+				return 2
+			}
+			fmt.Fprintf(os.Stdout, "Watching for updates on schedule %q (%s), next at %s. Press Ctrl+C to stop.\n", cron, cron.Location, next.Format(time.RFC3339))
+		} else {
+			fmt.Fprintf(os.Stdout, "Watching for updates every %s. Press Ctrl+C to stop.\n", (*interval).Truncate(time.Second))
+		}
 
-		scanner.Watch(ctx, *interval, func(results []upd8.Result) {
-			batch := results
-			if !*verbose {
-				batch = filterEmpty(batch)
+		changes := upd8.ChangeDetector{
+			SummaryEvery: time.Duration(summaryEvery),
+			// A single network or timeout failure is not worth an alert.
+			Transient: upd8.TransientFilter{Threshold: 2},
+		}
+		onScan := func(start time.Time, results []upd8.Result) {
+			if *offline {
+				results = fillOffline(cache, cfg.Cache, results)
+			}
+			rec := recordScan(ctx, store, cfg.History, !*noHistory, start, results)
+			cacheResults(ctx, cache, cfg.Cache, start, results)
+			if api != nil {
+				api.Publish(rec)
+			}
+			diff, summary := changes.Next(rec)
+			// History and the API keep the real errors; the report waits
+			// for a transient failure to repeat.
+			shownRec, shown := changes.Transient.Hide(rec, results)
+			switch {
+			case !*changesOnly || summary:
+				printResults(*format, renderer, *verbose, store, shownRec, shown)
+			case diff.Empty():
+				// Nothing new; stay quiet until something changes.
+			case *format == "table":
+				renderer.RenderDiff(diff)
+			default:
+				// Machine-readable consumers always get the full state.
+				emitRecord(*format, store, shownRec)
 			}
-			renderer.Render(batch)
-		})
+		}
+		if cron != nil {
+			scanner.WatchSchedule(ctx, upd8.Schedule{
+				Cron:   cron,
+				Jitter: time.Duration(jitter),
+				Missed: func(scheduled time.Time) {
+					fmt.Fprintf(os.Stderr, "Catching up on the scan scheduled for %s.\n", scheduled.Format(time.RFC3339))
+				},
+			}, onScan)
+		} else {
+			scanner.Watch(ctx, *interval, onScan)
+		}
+		// Watching only stops on interrupt, which shuts the API down too.
+		<-apiDone
 		return 0
 	}
 
+	start := time.Now()
 	results := scanner.Scan(ctx)
-	if !*verbose {
-		results = filterEmpty(results)
+	if *offline {
+		results = fillOffline(cache, cfg.Cache, results)
 	}
+	rec := recordScan(ctx, store, cfg.History, !*noHistory, start, results)
+	cacheResults(ctx, cache, cfg.Cache, start, results)
+	if recorder != nil {
+		if err := recorder.Save(*record, resolvedConfig(cfg, targets), *offline, *refresh); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+
+	printResults(*format, renderer, *verbose, store, rec, results)
+	return exitCode(results, cfg.Policy, start)
+}
 
+// printResults writes a finished scan in the requested format.
+func printResults(format string, renderer upd8.Renderer, verbose bool, store upd8.HistoryStore, rec upd8.ScanRecord, results []upd8.Result) {
+	if format != "table" {
+		emitRecord(format, store, rec)
+		return
+	}
+	if !verbose {
+		results = filterEmpty(results)
+	}
 	renderer.Render(results)
-	return computeExitCode(results)
+}
+
+// exitCode combines manager failures (1) with policy violations (3).
+func exitCode(results []upd8.Result, policy upd8.PolicyConfig, now time.Time) int {
+	code := computeExitCode(results)
+	if violations := upd8.CheckMaxAge(results, time.Duration(policy.MaxAge), now); len(violations) > 0 {
+		reportAgeViolations(violations, policy.MaxAge)
+		if code == 0 {
+			code = 3
+		}
+	}
+	return code
+}
+
+// emitRecord writes a scan in one of the machine-readable formats.
+func emitRecord(format string, store upd8.HistoryStore, rec upd8.ScanRecord) {
+	var err error
+	switch format {
+	case "json":
+		err = upd8.WriteJSON(os.Stdout, rec)
+	case "prometheus":
+		history, _ := store.List()
+		if rec.ID == "" {
+			history = append(history, rec)
+		}
+		err = upd8.WritePrometheus(os.Stdout, rec, history)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "write %s output: %v\n", format, err)
+	}
+}
+
+func reportAgeViolations(violations []upd8.AgeViolation, maxAge upd8.Duration) {
+	fmt.Fprintf(os.Stderr, "\n%d package(s) outdated for longer than %s:\n", len(violations), maxAge)
+	for _, v := range violations {
+		fmt.Fprintf(os.Stderr, "  %s %s %s → %s (%dd)\n", v.Manager, v.Item.Name, v.Item.Current, v.Item.Latest, int(v.Age/(24*time.Hour)))
+	}
+}
+
+// recordScan annotates results with outdated ages from past scans, then builds
+// the record for this scan and, if save is set, persists it to the history
+// store. Failures are reported but never fatal. Interrupted scans are not saved
+// so partial results do not pollute the history.
+func recordScan(ctx context.Context, store upd8.HistoryStore, cfg upd8.HistoryConfig, save bool, start time.Time, results []upd8.Result) upd8.ScanRecord {
+	if cfg.Disabled {
+		return upd8.NewScanRecord(start, results)
+	}
+
+	history, err := store.Recent(ageHistoryDepth)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not read scan history: %v\n", err)
+	}
+	upd8.AnnotateAges(results, history, start)
+
+	rec := upd8.NewScanRecord(start, results)
+	if !save || ctx.Err() != nil {
+		return rec
+	}
+	if err := store.Save(&rec); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not record scan history: %v\n", err)
+	}
+	return rec
 }
 
 func filterEmpty(results []upd8.Result) []upd8.Result {
 	filtered := make([]upd8.Result, 0, len(results))
 	for _, r := range results {
-		if r.Err != nil || len(r.Items) > 0 {
+		if r.Err != nil || r.Skipped != "" || len(r.Items) > 0 {
 			filtered = append(filtered, r)
 		}
 	}
@@ -0,0 +1,169 @@
+package upd8
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// AnnotateAges fills OutdatedSince, OutdatedDays and ReleasesBehind on every item using past
+// scans (oldest first). An item counts as continuously outdated for as long as
+// it appears in consecutive successful results of its manager; failed checks
+// neither extend nor break that streak.
+//
+// ReleasesBehind is the number of distinct latest versions observed while the
+// installed version stayed the same, so it is always at least 1.
+func AnnotateAges(results []Result, history []ScanRecord, now time.Time) {
+	for i := range results {
+		res := &results[i]
+		if res.Err != nil {
+			continue
+		}
+		for j := range res.Items {
+			annotateItem(res.Key(), &res.Items[j], history, now)
+		}
+	}
+}
+
+func annotateItem(key string, item *Item, history []ScanRecord, now time.Time) {
+	since := now.UTC()
+	behind := 1
+	latest := item.Latest
+
+	for i := len(history) - 1; i >= 0; i-- {
+		prev, ok := findItem(history[i], key, item.Name)
+		if !ok {
+			if managerSucceeded(history[i], key) {
+				break
+			}
+			continue
+		}
+		if prev.Current != item.Current {
+			break
+		}
+
+		if prev.Latest != latest {
+			behind++
+			latest = prev.Latest
+		}
+
+		// Annotated records already carry the streak forward, so stop there.
+		if prev.OutdatedSince != nil {
+			since = prev.OutdatedSince.UTC()
+			behind += max(prev.ReleasesBehind, 1) - 1
+			break
+		}
+		since = history[i].Time.UTC()
+	}
+
+	item.OutdatedSince = &since
+	item.OutdatedDays = int(now.Sub(since) / (24 * time.Hour))
+	item.ReleasesBehind = behind
+}
+
+func findItem(rec ScanRecord, key, name string) (Item, bool) {
+	for _, r := range rec.Results {
+		if r.Key() != key || r.Error != "" {
+			continue
+		}
+		for _, item := range r.Items {
+			if item.Name == name {
+				return item, true
+			}
+		}
+	}
+	return Item{}, false
+}
+
+func managerSucceeded(rec ScanRecord, key string) bool {
+	for _, r := range rec.Results {
+		if r.Key() == key {
+			return r.Error == "" && r.Skipped == ""
+		}
+	}
+	return false
+}
+
+// OutdatedFor returns how long the item has been outdated, or zero when unknown.
+func (i Item) OutdatedFor(now time.Time) time.Duration {
+	if i.OutdatedSince == nil {
+		return 0
+	}
+	return now.Sub(*i.OutdatedSince)
+}
+
+// OldestOutdated returns the longest OutdatedFor across a result's items.
+func (r Result) OldestOutdated(now time.Time) time.Duration {
+	var oldest time.Duration
+	for _, item := range r.Items {
+		if age := item.OutdatedFor(now); age > oldest {
+			oldest = age
+		}
+	}
+	return oldest
+}
+
+// AgeViolation is an item that has been outdated for longer than allowed.
+type AgeViolation struct {
+	Manager string
+	Item    Item
+	Age     time.Duration
+}
+
+// CheckMaxAge returns every item outdated for longer than maxAge, oldest first.
+func CheckMaxAge(results []Result, maxAge time.Duration, now time.Time) []AgeViolation {
+	if maxAge <= 0 {
+		return nil
+	}
+
+	var violations []AgeViolation
+	for _, r := range results {
+		for _, item := range r.Items {
+			if age := item.OutdatedFor(now); age > maxAge {
+				violations = append(violations, AgeViolation{Manager: r.Key(), Item: item, Age: age})
+			}
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool { return violations[i].Age > violations[j].Age })
+	return violations
+}
+
+// MeanTimeToUpdate averages, over every package resolved within the given
+// scans, how long it had been outdated before it disappeared. It returns the
+// mean and the number of resolved packages it is based on.
+func MeanTimeToUpdate(history []ScanRecord) (time.Duration, int) {
+	if len(history) == 0 {
+		return 0, 0
+	}
+	var total time.Duration
+	count := 0
+	// Packages resolved while a manager was failing still count once it
+	// recovers.
+	base := history[0]
+	for _, rec := range history[1:] {
+		diff := DiffScans(base, rec)
+		for _, c := range diff.Resolved {
+			if c.Item.OutdatedSince == nil {
+				continue
+			}
+			total += rec.Time.Sub(*c.Item.OutdatedSince)
+			count++
+		}
+		base = carryForward(base, rec)
+	}
+	if count == 0 {
+		return 0, 0
+	}
+	return total / time.Duration(count), count
+}
+
+// formatAge renders an outdated duration compactly, e.g. "12d" or "<1d".
+func formatAge(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	if d < 24*time.Hour {
+		return "<1d"
+	}
+	return fmt.Sprintf("%dd", d/(24*time.Hour))
+}
@@ -0,0 +1,300 @@
+package upd8
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultHistoryLimit is how many scans GET /v1/history returns by default.
+const defaultHistoryLimit = 50
+
+// APIServer serves a watching daemon's latest results over HTTP:
+//
+//	GET  /v1/health             liveness and the time of the last scan
+//	GET  /v1/results            the latest scan
+//	GET  /v1/results/{key}      one manager of the latest scan, by result key
+//	GET  /v1/history?limit=N    recent scans from the history store
+//	POST /v1/scan[?wait=true]   queue an immediate scan, optionally waiting for it
+//	POST /v1/apply              upgrade outdated packages of one manager
+//	GET  /                      the web dashboard
+//
+// The daemon publishes each finished scan with Publish and runs the scans
+// requested through Rescan.
+type APIServer struct {
+	History HistoryStore
+	// Upgrade, if set, enables POST /v1/apply; see MultiScanner.Upgrade.
+	Upgrade func(ctx context.Context, key string, pkgs []string) error
+
+	started time.Time
+	rescan  chan struct{}
+	// upgrading is held while an upgrade runs; there is only ever one.
+	upgrading sync.Mutex
+
+	mu     sync.Mutex
+	latest *ScanRecord
+	// updated is closed and replaced whenever a scan is published.
+	updated chan struct{}
+}
+
+// NewAPIServer returns a server that reads past scans from history.
+func NewAPIServer(history HistoryStore) *APIServer {
+	return &APIServer{
+		History: history,
+		started: time.Now(),
+		rescan:  make(chan struct{}, 1),
+		updated: make(chan struct{}),
+	}
+}
+
+// Rescan receives once for every pending scan request; pass it to
+// Scanner.Rescan. Requests made while one is pending are merged into it.
+func (s *APIServer) Rescan() <-chan struct{} { return s.rescan }
+
+// Publish makes rec the latest scan.
+func (s *APIServer) Publish(rec ScanRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latest = &rec
+	close(s.updated)
+	s.updated = make(chan struct{})
+}
+
+func (s *APIServer) snapshot() (*ScanRecord, chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latest, s.updated
+}
+
+// Handler returns the API's routes.
+func (s *APIServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/health", s.health)
+	mux.HandleFunc("GET /v1/results", s.results)
+	mux.HandleFunc("GET /v1/results/{key...}", s.result)
+	mux.HandleFunc("GET /v1/history", s.history)
+	mux.HandleFunc("POST /v1/scan", s.scan)
+	mux.HandleFunc("POST /v1/apply", s.apply)
+	mux.Handle("GET /", dashboardHandler())
+	return mux
+}
+
+func (s *APIServer) health(w http.ResponseWriter, r *http.Request) {
+	latest, _ := s.snapshot()
+	resp := struct {
+		Status        string     `json:"status"`
+		Started       time.Time  `json:"started"`
+		LastScan      *time.Time `json:"last_scan,omitempty"`
+		Outdated      int        `json:"outdated"`
+		Errors        int        `json:"errors"`
+		RescanPending bool       `json:"rescan_pending"`
+		Apply         bool       `json:"apply"`
+	}{Status: "ok", Started: s.started, RescanPending: len(s.rescan) > 0, Apply: s.Upgrade != nil}
+	if latest != nil {
+		resp.LastScan = &latest.Time
+		resp.Outdated = latest.Outdated()
+		resp.Errors = latest.Errors()
+	}
+	writeAPIJSON(w, http.StatusOK, resp)
+}
+
+func (s *APIServer) results(w http.ResponseWriter, r *http.Request) {
+	latest, _ := s.snapshot()
+	if latest == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, "no scan has finished yet")
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, latest)
+}
+
+// result looks a manager up by its result key: "brew" on this machine,
+// "user@box/brew" on a remote host.
+func (s *APIServer) result(w http.ResponseWriter, r *http.Request) {
+	latest, _ := s.snapshot()
+	if latest == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, "no scan has finished yet")
+		return
+	}
+	key := r.PathValue("key")
+	for _, res := range latest.Results {
+		if res.Key() == key {
+			writeAPIJSON(w, http.StatusOK, res)
+			return
+		}
+	}
+	writeAPIError(w, http.StatusNotFound, "no manager "+strconv.Quote(key)+" in the latest scan")
+}
+
+func (s *APIServer) history(w http.ResponseWriter, r *http.Request) {
+	limit := defaultHistoryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeAPIError(w, http.StatusBadRequest, "limit must be a non-negative number")
+			return
+		}
+		limit = n
+	}
+	records, err := s.History.Recent(limit)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if records == nil {
+		records = []ScanRecord{}
+	}
+	writeAPIJSON(w, http.StatusOK, records)
+}
+
+// scan queues a scan. With wait=true the response is the first scan that
+// started after the request, once it has finished.
+func (s *APIServer) scan(w http.ResponseWriter, r *http.Request) {
+	requested := time.Now()
+	queued := true
+	select {
+	case s.rescan <- struct{}{}:
+	default:
+		queued = false
+	}
+
+	if wait, _ := strconv.ParseBool(r.URL.Query().Get("wait")); !wait {
+		status := "queued"
+		if !queued {
+			status = "already queued"
+		}
+		writeAPIJSON(w, http.StatusAccepted, map[string]string{"status": status})
+		return
+	}
+	for {
+		latest, updated := s.snapshot()
+		// Record times carry no monotonic reading, so compare wall clocks.
+		if latest != nil && !latest.Time.Before(requested.Round(0)) {
+			writeAPIJSON(w, http.StatusOK, latest)
+			return
+		}
+		select {
+		case <-updated:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// apply upgrades packages the latest scan reported as outdated, then queues
+// a scan so the results reflect it. Anything else is refused, so the API
+// cannot be used to install arbitrary packages.
+func (s *APIServer) apply(w http.ResponseWriter, r *http.Request) {
+	if s.Upgrade == nil {
+		writeAPIError(w, http.StatusForbidden, "upgrades are disabled; start the daemon with --allow-apply")
+		return
+	}
+	var req struct {
+		Manager  string   `json:"manager"`
+		Packages []string `json:"packages"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if len(req.Packages) == 0 {
+		writeAPIError(w, http.StatusBadRequest, "no packages selected")
+		return
+	}
+
+	latest, _ := s.snapshot()
+	if latest == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, "no scan has finished yet")
+		return
+	}
+	outdated := map[string]bool{}
+	for _, res := range latest.Results {
+		if res.Key() == req.Manager {
+			for _, item := range res.Items {
+				outdated[item.Name] = true
+			}
+		}
+	}
+	for _, pkg := range req.Packages {
+		if !outdated[pkg] || strings.HasPrefix(pkg, "-") {
+			writeAPIError(w, http.StatusBadRequest, strconv.Quote(pkg)+" is not an outdated package of "+strconv.Quote(req.Manager))
+			return
+		}
+	}
+
+	if !s.upgrading.TryLock() {
+		writeAPIError(w, http.StatusConflict, "another upgrade is still running")
+		return
+	}
+	defer s.upgrading.Unlock()
+	// A closed browser tab must not kill a package manager halfway through.
+	err := s.Upgrade(context.WithoutCancel(r.Context()), req.Manager, req.Packages)
+	select {
+	case s.rescan <- struct{}{}:
+	default:
+	}
+	if err != nil {
+		resp := map[string]string{"error": err.Error()}
+		var ce *CheckError
+		if errors.As(err, &ce) && ce.Hint != "" {
+			resp["hint"] = ce.Hint
+		}
+		writeAPIJSON(w, http.StatusBadGateway, resp)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, map[string]any{"status": "upgraded", "manager": req.Manager, "packages": req.Packages})
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, msg string) {
+	writeAPIJSON(w, status, map[string]string{"error": msg})
+}
+
+// LoopbackOnly guards a handler listening on a loopback TCP port against
+// other web pages in the user's browser: requests must name a loopback host
+// (defeating DNS rebinding), and requests sent from a page must come from
+// the same origin (defeating cross-site POSTs).
+func LoopbackOnly(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLoopbackHost(r.Host) {
+			writeAPIError(w, http.StatusForbidden, "only loopback host names are accepted")
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || u.Host != r.Host {
+				writeAPIError(w, http.StatusForbidden, "cross-origin requests are not accepted")
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// isLoopbackHost reports whether hostport names this machine.
+func isLoopbackHost(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,191 @@
+package upd8
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ResultCache keeps the latest Result of each manager on disk, one file per
+// manager, so status bars and prompts can read results without scanning.
+type ResultCache struct {
+	Dir string
+	TTL time.Duration
+}
+
+// CacheEntry is a cached result together with the time it was stored.
+type CacheEntry struct {
+	CachedAt time.Time    `json:"cached_at"`
+	Result   ResultRecord `json:"result"`
+}
+
+// NewResultCache returns a cache under CacheDir using the configured TTL.
+func NewResultCache(cfg CacheConfig) ResultCache {
+	return ResultCache{
+		Dir: filepath.Join(CacheDir(), "results"),
+		TTL: time.Duration(cfg.TTL),
+	}
+}
+
+// Store writes the results of a live scan to the cache and drops the entries
+// of managers the scan no longer found; see Prune.
+func (c ResultCache) Store(results []Result, now time.Time) error {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return fmt.Errorf("create cache dir: %w", err)
+	}
+
+	for _, r := range results {
+		// Never re-cache something that was itself served from the cache, or
+		// overwrite a real result with a skipped one.
+		if !r.CachedAt.IsZero() || r.Skipped != "" {
+			continue
+		}
+		entry := CacheEntry{CachedAt: now.UTC(), Result: NewResultRecord(r)}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("encode cache entry for %s: %w", r.Key(), err)
+		}
+
+		path := c.path(r.Key())
+		if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+			return fmt.Errorf("write cache entry for %s: %w", r.Key(), err)
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			return fmt.Errorf("write cache entry for %s: %w", r.Key(), err)
+		}
+	}
+	return c.Prune(results)
+}
+
+// Prune drops the cached results of managers missing from a live scan of
+// their host, such as managers that were uninstalled. Hosts on which the scan
+// found no manager at all, as when the connection failed, are left alone.
+func (c ResultCache) Prune(results []Result) error {
+	scanned := make(map[string]bool)
+	current := make(map[string]bool, len(results))
+	for _, r := range results {
+		current[r.Key()] = true
+		if r.Detection.Available {
+			scanned[r.Host] = true
+		}
+	}
+
+	var stale []string
+	for _, entry := range c.All() {
+		if scanned[entry.Result.Host] && !current[entry.Result.Key()] {
+			stale = append(stale, entry.Result.Key())
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	return c.Invalidate(stale...)
+}
+
+// Load returns the cached result for one manager, given by result key (see
+// Result.Key).
+func (c ResultCache) Load(key string) (CacheEntry, bool) {
+	var entry CacheEntry
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return entry, false
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, false
+	}
+	return entry, true
+}
+
+// All returns every cached entry sorted by manager name.
+func (c ResultCache) All() []CacheEntry {
+	files, err := filepath.Glob(filepath.Join(c.Dir, "*.json"))
+	if err != nil {
+		return nil
+	}
+
+	entries := make([]CacheEntry, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var entry CacheEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Result.Manager < entries[j].Result.Manager })
+	return entries
+}
+
+// FillOffline replaces the results of managers skipped only because the scan
+// was offline with their cached results, which are shown with their age.
+// Managers skipped for any other reason, such as needing root, stay skipped:
+// their cached result is no more current than the skip says.
+func (c ResultCache) FillOffline(results []Result) []Result {
+	for i, r := range results {
+		if r.Skipped != offlineReason {
+			continue
+		}
+		if entry, ok := c.Load(r.Key()); ok {
+			cached := entry.ToResult()
+			cached.Detection = r.Detection
+			results[i] = cached
+		}
+	}
+	return results
+}
+
+// Fresh reports whether an entry is younger than the cache TTL.
+func (c ResultCache) Fresh(entry CacheEntry, now time.Time) bool {
+	return c.TTL > 0 && now.Sub(entry.CachedAt) < c.TTL
+}
+
+// Invalidate drops the cached results of the given managers (by result key),
+// or of every manager when none are given.
+func (c ResultCache) Invalidate(managers ...string) error {
+	var paths []string
+	if len(managers) == 0 {
+		paths, _ = filepath.Glob(filepath.Join(c.Dir, "*.json"))
+	} else {
+		for _, m := range managers {
+			paths = append(paths, c.path(m))
+		}
+	}
+
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("invalidate cache: %w", err)
+		}
+	}
+	return nil
+}
+
+// ToResult converts the entry into a Result marked as served from cache.
+func (e CacheEntry) ToResult() Result {
+	r := e.Result.Result()
+	r.CachedAt = e.CachedAt
+	return r
+}
+
+func (c ResultCache) path(manager string) string {
+	return filepath.Join(c.Dir, cacheKey(manager)+".json")
+}
+
+// cacheKey turns a manager name into a safe file name.
+func cacheKey(manager string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, manager)
+}
@@ -0,0 +1,74 @@
+package upd8
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFillOffline(t *testing.T) {
+	cache := ResultCache{Dir: t.TempDir()}
+	stored := []Result{
+		{Manager: "cargo", Items: []Item{{Name: "ripgrep", Latest: "14.1.1"}}},
+		{Manager: "snap", Items: []Item{{Name: "firefox", Latest: "128.0"}}},
+	}
+	if err := cache.Store(stored, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	results := cache.FillOffline([]Result{
+		{Manager: "cargo", Skipped: offlineReason},
+		{Manager: "snap", Skipped: needsRootReason},
+		{Manager: "npm", Skipped: offlineReason},
+	})
+	if r := results[0]; r.Skipped != "" || r.CachedAt.IsZero() || len(r.Items) != 1 {
+		t.Errorf("cargo = %+v, want its cached result", r)
+	}
+	if r := results[1]; r.Skipped != needsRootReason || !r.CachedAt.IsZero() {
+		t.Errorf("snap = %+v, want it to stay skipped", r)
+	}
+	if r := results[2]; r.Skipped != offlineReason {
+		t.Errorf("npm = %+v, want it to stay skipped without a cached result", r)
+	}
+}
+
+func TestStorePrunesMissingManagers(t *testing.T) {
+	cache := ResultCache{Dir: t.TempDir()}
+	found := Detection{Available: true}
+	now := time.Now()
+	if err := cache.Store([]Result{
+		{Manager: "cargo", Detection: found},
+		{Manager: "npm", Detection: found},
+		{Host: "box", Manager: "pip3", Detection: found},
+	}, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// npm was uninstalled here; box was not scanned.
+	if err := cache.Store([]Result{{Manager: "cargo", Detection: found}}, now); err != nil {
+		t.Fatal(err)
+	}
+	// A failed connection says nothing about the managers on box.
+	if err := cache.Store([]Result{{Host: "box", Manager: "ssh", Err: ErrNetwork}}, now); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := func() []string {
+		var keys []string
+		for _, entry := range cache.All() {
+			keys = append(keys, entry.Result.Key())
+		}
+		return keys
+	}
+	if got, want := keys(), []string{"cargo", "box/pip3", "box/ssh"}; !slices.Equal(got, want) {
+		t.Errorf("cached %v, want %v", got, want)
+	}
+
+	// Once box is back, its connection error goes too.
+	if err := cache.Store([]Result{{Host: "box", Manager: "pip3", Detection: found}}, now); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := keys(), []string{"cargo", "box/pip3"}; !slices.Equal(got, want) {
+		t.Errorf("cached %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,203 @@
+package upd8
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds optional user settings loaded from config.json.
+type Config struct {
+	History HistoryConfig `json:"history"`
+	Policy  PolicyConfig  `json:"policy"`
+	Cache   CacheConfig   `json:"cache"`
+	Scan    ScanConfig    `json:"scan"`
+	Python  PythonConfig  `json:"python"`
+	Node    NodeConfig    `json:"node"`
+	// Hosts lists the machines to scan, as ssh destinations or "local" for
+	// this one. Empty means only this machine.
+	Hosts []string `json:"hosts"`
+	// Containers lists running containers to scan with ContainerEngine
+	// ("docker" or "podman"; empty picks whichever is installed).
+	Containers      []string   `json:"containers"`
+	ContainerEngine string     `json:"container_engine"`
+	Root            RootConfig `json:"root"`
+}
+
+// HistoryConfig controls whether scans are persisted and how long they are kept.
+type HistoryConfig struct {
+	Disabled bool     `json:"disabled"`
+	Keep     int      `json:"keep"`
+	MaxAge   Duration `json:"max_age"`
+}
+
+// PolicyConfig holds thresholds that turn findings into a failing exit code.
+type PolicyConfig struct {
+	// MaxAge fails the run when any package has been outdated for longer.
+	MaxAge Duration `json:"max_age"`
+}
+
+// CacheConfig controls how long cached manager results stay fresh.
+type CacheConfig struct {
+	Disabled bool     `json:"disabled"`
+	TTL      Duration `json:"ttl"`
+}
+
+// ScanConfig controls how managers are scheduled during a scan.
+type ScanConfig struct {
+	// MaxConcurrency limits how many managers are checked at once; 0 means no limit.
+	MaxConcurrency int `json:"max_concurrency"`
+	// Timeout bounds each command run by a manager.
+	Timeout Duration `json:"timeout"`
+	// Timeouts bounds whole manager checks by name, e.g. {"flatpak": "3m"}.
+	Timeouts map[string]Duration `json:"timeouts"`
+	// Retries is how many more times a check that failed with a network or
+	// timeout error is attempted.
+	Retries int `json:"retries"`
+	// RetryBackoff is the delay before the first retry; it doubles each time.
+	RetryBackoff Duration `json:"retry_backoff"`
+	// KillGrace is how long a timed-out command gets to exit after SIGTERM
+	// before its whole process group is killed.
+	KillGrace Duration `json:"kill_grace"`
+	// MaxOutputBytes caps the captured stdout and stderr of each command.
+	MaxOutputBytes int `json:"max_output_bytes"`
+	// Refresh turns the metadata refresh before checking (e.g. `brew update`)
+	// on or off per manager name; --refresh enables it for all.
+	Refresh map[string]bool `json:"refresh"`
+	// RefreshTimeout bounds each manager's refresh step.
+	RefreshTimeout Duration `json:"refresh_timeout"`
+	// Env sets extra environment variables per manager, e.g.
+	// {"brew": {"HOMEBREW_CURL_RETRIES": "3"}}. A base name such as "pip"
+	// also covers discovered managers like "pip:pyenv-3.12.1".
+	Env map[string]map[string]string `json:"env"`
+	// RequiresRoot marks managers that only work as root, by name or base
+	// name. Without Sudo they are skipped when upd8 is not root. Setting it
+	// either way overrides the built-in rootDefaults.
+	RequiresRoot map[string]bool `json:"requires_root"`
+	// Sudo is the command used to run them as root: "sudo", "doas" or
+	// "auto" for whichever is installed; --sudo sets "auto".
+	Sudo string `json:"sudo"`
+}
+
+// PythonConfig controls which Python environments are checked besides pip on PATH.
+type PythonConfig struct {
+	// DisableDiscovery skips pyenv, conda, uv and ~/.local user installs.
+	DisableDiscovery bool `json:"disable_discovery"`
+	// Virtualenvs lists extra environment directories to check ("~" is expanded).
+	Virtualenvs []string `json:"virtualenvs"`
+}
+
+// NodeConfig controls discovery of Node.js versions managed by nvm, fnm,
+// volta, asdf and mise.
+type NodeConfig struct {
+	DisableDiscovery bool `json:"disable_discovery"`
+}
+
+// DefaultConfig returns the settings used when no config file is present.
+func DefaultConfig() Config {
+	return Config{
+		History: HistoryConfig{
+			Keep:   1000,
+			MaxAge: Duration(180 * 24 * time.Hour),
+		},
+		Cache: CacheConfig{
+			TTL: Duration(time.Hour),
+		},
+		Scan: ScanConfig{
+			Timeout:        Duration(60 * time.Second),
+			KillGrace:      Duration(5 * time.Second),
+			MaxOutputBytes: 16 << 20,
+			Retries:        2,
+			RefreshTimeout: Duration(5 * time.Minute),
+			RetryBackoff:   Duration(2 * time.Second),
+		},
+	}
+}
+
+// DefaultConfigPath returns the location of the user's config file.
+func DefaultConfigPath() string {
+	return filepath.Join(ConfigDir(), "config.json")
+}
+
+// LoadConfig reads a JSON config file on top of DefaultConfig.
+// A missing file is not an error.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// ManagerTimeouts converts the configured per-manager timeouts for a Scanner.
+func (c ScanConfig) ManagerTimeouts() map[string]time.Duration {
+	out := make(map[string]time.Duration, len(c.Timeouts))
+	for name, d := range c.Timeouts {
+		out[name] = time.Duration(d)
+	}
+	return out
+}
+
+// Duration is a time.Duration that also accepts day suffixes ("30d") and
+// can be used both in JSON config and as a command-line flag.
+type Duration time.Duration
+
+// ParseDuration extends time.ParseDuration with a "d" (24h) unit.
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+func (d Duration) String() string {
+	v := time.Duration(d)
+	if v > 0 && v%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", v/(24*time.Hour))
+	}
+	return v.String()
+}
+
+// Set implements flag.Value.
+func (d *Duration) Set(s string) error {
+	v, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"90s\" or \"30d\"")
+	}
+	return d.Set(s)
+}
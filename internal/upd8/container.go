@@ -0,0 +1,99 @@
+package upd8
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// containerEngines are the supported container CLIs in order of preference.
+var containerEngines = []string{"docker", "podman"}
+
+// ContainerRunner runs commands inside a running container with
+// `docker exec` or `podman exec`, as the container's default user.
+type ContainerRunner struct {
+	// Engine is the container CLI, "docker" or "podman".
+	Engine    string
+	Container string
+	// Env holds extra KEY=VALUE pairs set for the command on top of the
+	// commandEnv policy.
+	Env []string
+	// Local runs the engine CLI itself and supplies the timeout, kill and
+	// output cap behavior.
+	Local ExecRunner
+}
+
+// NewContainerRunner returns a runner for container using engine.
+func NewContainerRunner(engine, container string, cfg ScanConfig) ContainerRunner {
+	return ContainerRunner{Engine: engine, Container: container, Local: NewExecRunner(cfg)}
+}
+
+// containerTimeout runs a command under timeout(1) inside the container, if
+// the image has a working one. Killing the local `docker exec` client on
+// timeout does not stop the process it started in the container.
+const containerTimeout = `s=$1; shift; if timeout 1 true 2>/dev/null; then exec timeout "$s" "$@"; fi; exec "$@"`
+
+// Run executes cmd in the container. The image's environment gets the same
+// policy as local commands; see policyCommand. The command is also stopped
+// inside the container once the local deadline passes, except in images
+// without timeout(1), where it may outlive the scan.
+func (r ContainerRunner) Run(ctx context.Context, cmd string, args ...string) CommandResult {
+	budget := r.Local.Timeout
+	if budget <= 0 {
+		budget = defaultTimeout
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		budget = time.Until(deadline)
+	}
+	// timeout(1) treats 0 as no limit.
+	secs := strconv.Itoa(max(1, int(math.Ceil(budget.Seconds()))))
+	execArgs := append([]string{"exec", r.Container, "sh", "-c", containerTimeout, "sh", secs}, policyCommand(r.Env, cmd, args)...)
+	return r.Local.Run(ctx, r.Engine, execArgs...)
+}
+
+// WithEnv returns a copy of r that also sets env inside the container.
+func (r ContainerRunner) WithEnv(env []string) CommandRunner {
+	r.Env = append(append([]string(nil), r.Env...), env...)
+	return r
+}
+
+// Ping checks that the container exists and is running.
+func (r ContainerRunner) Ping(ctx context.Context) error {
+	op := r.Engine + " " + r.Container
+	hints := Hints{
+		ErrPrerequisite: "start it with `" + r.Engine + " start " + r.Container + "`",
+		ErrNotInstalled: "no such container; `" + r.Engine + " ps` lists the running ones",
+	}
+	res := r.Local.Run(ctx, r.Engine, "inspect", "--format", "{{.State.Running}}", r.Container)
+	if res.Error != nil {
+		return commandError(op, res, hints)
+	}
+	if strings.TrimSpace(string(res.Stdout)) != "true" {
+		return &CheckError{Kind: ErrPrerequisite, Op: op, Err: errors.New("container is not running"), Hint: hints[ErrPrerequisite]}
+	}
+	return nil
+}
+
+// DetectContainerEngine returns the first container CLI found on PATH, or ""
+// when there is none.
+func DetectContainerEngine() string {
+	for _, engine := range containerEngines {
+		if _, err := exec.LookPath(engine); err == nil {
+			return engine
+		}
+	}
+	return ""
+}
+
+// ListContainers returns the names of all running containers.
+func ListContainers(ctx context.Context, engine string, cfg ScanConfig) ([]string, error) {
+	res := NewExecRunner(cfg).Run(ctx, engine, "ps", "--format", "{{.Names}}")
+	if res.Error != nil {
+		return nil, commandError(engine+" ps", res, nil)
+	}
+	return strings.Fields(string(res.Stdout)), nil
+}
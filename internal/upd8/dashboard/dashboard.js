@@ -0,0 +1,222 @@
+// upd8 dashboard: renders the daemon's latest scan and recent history from
+// the API on the same origin. Everything from the API is inserted as text.
+"use strict";
+
+const REFRESH_MS = 30000;
+const HISTORY_LIMIT = 30;
+
+let applyEnabled = false;
+let busy = false;
+
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [key, value] of Object.entries(attrs || {})) {
+    if (key === "class") {
+      node.className = value;
+    } else {
+      node.setAttribute(key, value);
+    }
+  }
+  for (const child of children) {
+    if (child !== null && child !== undefined) {
+      node.append(child);
+    }
+  }
+  return node;
+}
+
+async function api(path, options) {
+  const resp = await fetch(path, options);
+  const body = await resp.json().catch(() => ({}));
+  if (!resp.ok) {
+    const err = new Error(body.error || resp.statusText);
+    err.hint = body.hint;
+    err.status = resp.status;
+    throw err;
+  }
+  return body;
+}
+
+function resultKey(r) {
+  return r.host ? r.host + "/" + r.manager : r.manager;
+}
+
+function notice(text, isError) {
+  const node = document.getElementById("notice");
+  node.hidden = !text;
+  node.textContent = text || "";
+  node.className = isError ? "error" : "";
+}
+
+// sparkline draws the number of outdated packages per scan, oldest first.
+function sparkline(counts) {
+  const ns = "http://www.w3.org/2000/svg";
+  const width = 120;
+  const height = 24;
+  const svg = document.createElementNS(ns, "svg");
+  svg.setAttribute("width", width);
+  svg.setAttribute("height", height);
+  svg.setAttribute("viewBox", `0 0 ${width} ${height}`);
+  if (counts.length < 2) {
+    return svg;
+  }
+  const max = Math.max(1, ...counts);
+  const step = width / (counts.length - 1);
+  const points = counts.map((c, i) => `${(i * step).toFixed(1)},${(height - 2 - (c / max) * (height - 4)).toFixed(1)}`);
+  const line = document.createElementNS(ns, "polyline");
+  line.setAttribute("class", "spark");
+  line.setAttribute("points", points.join(" "));
+  const title = document.createElementNS(ns, "title");
+  title.textContent = `Outdated packages over the last ${counts.length} scans: ${counts.join(", ")}`;
+  svg.append(title, line);
+  return svg;
+}
+
+function historyCounts(history) {
+  const counts = new Map();
+  history.forEach((scan, i) => {
+    for (const r of scan.results || []) {
+      const key = resultKey(r);
+      if (!counts.has(key)) {
+        counts.set(key, new Array(history.length).fill(null));
+      }
+      counts.get(key)[i] = r.error || r.skipped ? null : (r.items || []).length;
+    }
+  });
+  // Scans where the manager failed or was not checked leave gaps; drop them.
+  for (const [key, values] of counts) {
+    counts.set(key, values.filter((v) => v !== null));
+  }
+  return counts;
+}
+
+function renderManager(r, counts) {
+  const key = resultKey(r);
+  const items = r.items || [];
+  const heading = el("h2", null, key, el("span", { class: "count" }, r.error ? "failed" : `${items.length} outdated`), sparkline(counts.get(key) || []));
+  const section = el("section", null, heading);
+
+  if (r.error) {
+    section.append(el("p", { class: "error-text" }, r.error));
+    if (r.error_hint) {
+      section.append(el("p", { class: "hint" }, r.error_hint));
+    }
+    return section;
+  }
+
+  const selectable = applyEnabled && items.length > 0;
+  const head = el("tr", null, selectable ? el("th", null) : null, el("th", null, "Package"), el("th", null, "Installed"), el("th", null, "Latest"), el("th", null, "Outdated since"));
+  const body = el("tbody");
+  for (const item of items) {
+    const box = selectable ? el("input", { type: "checkbox", value: item.name, "aria-label": item.name }) : null;
+    const since = item.outdated_since ? new Date(item.outdated_since).toLocaleDateString() : "";
+    body.append(el("tr", null, box ? el("td", null, box) : null, el("td", null, item.name), el("td", null, item.current || "?"), el("td", { class: "latest" }, item.latest || "?"), el("td", null, since)));
+  }
+  section.append(el("table", null, el("thead", null, head), body));
+
+  if (r.update_command) {
+    section.append(el("p", { class: "command" }, "Update all: ", el("code", null, r.update_command)));
+  }
+  if (selectable) {
+    const button = el("button", { type: "button" }, "Update selected…");
+    button.addEventListener("click", () => {
+      const pkgs = [...body.querySelectorAll("input:checked")].map((b) => b.value);
+      applyUpdates(key, pkgs);
+    });
+    section.append(el("div", { class: "actions" }, button));
+  }
+  return section;
+}
+
+async function load() {
+  if (busy) {
+    return;
+  }
+  try {
+    const health = await api("v1/health");
+    applyEnabled = health.apply;
+    if (!health.last_scan) {
+      document.getElementById("summary").textContent = "Waiting for the first scan…";
+      return;
+    }
+    const [scan, history] = await Promise.all([api("v1/results"), api(`v1/history?limit=${HISTORY_LIMIT}`)]);
+    const counts = historyCounts(history);
+
+    const outdated = scan.results.reduce((n, r) => n + (r.items || []).length, 0);
+    const failed = scan.results.filter((r) => r.error).length;
+    let summary = `${outdated} outdated package(s)`;
+    if (failed) {
+      summary += `, ${failed} failing manager(s)`;
+    }
+    summary += ` · last scan ${new Date(scan.time).toLocaleString()}`;
+    document.getElementById("summary").textContent = summary;
+
+    const managers = document.getElementById("managers");
+    const upToDate = [];
+    managers.replaceChildren();
+    for (const r of scan.results) {
+      if (r.error || (r.items || []).length > 0) {
+        managers.append(renderManager(r, counts));
+      } else if (!r.skipped) {
+        upToDate.push(resultKey(r));
+      }
+    }
+    document.getElementById("uptodate").textContent = upToDate.length ? "Up to date: " + upToDate.join(", ") : "";
+  } catch (err) {
+    notice(`Could not load results: ${err.message}`, true);
+  }
+}
+
+async function withBusy(label, fn) {
+  const rescan = document.getElementById("rescan");
+  busy = true;
+  rescan.disabled = true;
+  document.querySelectorAll("section button").forEach((b) => (b.disabled = true));
+  notice(label, false);
+  try {
+    await fn();
+  } finally {
+    busy = false;
+    rescan.disabled = false;
+    await load();
+  }
+}
+
+function rescan() {
+  withBusy("Scanning…", async () => {
+    try {
+      await api("v1/scan?wait=true", { method: "POST" });
+      notice("", false);
+    } catch (err) {
+      notice(`Rescan failed: ${err.message}`, true);
+    }
+  });
+}
+
+function applyUpdates(key, pkgs) {
+  if (pkgs.length === 0) {
+    notice("Select the packages to update first.", true);
+    return;
+  }
+  if (!confirm(`Upgrade ${pkgs.length} package(s) with ${key}?\n\n${pkgs.join("\n")}`)) {
+    return;
+  }
+  withBusy(`Upgrading ${pkgs.join(", ")} with ${key}…`, async () => {
+    try {
+      await api("v1/apply", {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ manager: key, packages: pkgs }),
+      });
+      notice(`Upgraded ${pkgs.join(", ")}. Rescanning…`, false);
+      await api("v1/scan?wait=true", { method: "POST" });
+      notice("", false);
+    } catch (err) {
+      notice(`Upgrade failed: ${err.message}${err.hint ? " (" + err.hint + ")" : ""}`, true);
+    }
+  });
+}
+
+document.getElementById("rescan").addEventListener("click", rescan);
+load();
+setInterval(load, REFRESH_MS);
@@ -1,18 +1,85 @@
 package upd8
 
-import "context"
-
-// DefaultManagers returns the built-in package manager implementations.
-func DefaultManagers(runner CommandRunner) []Manager {
-	return []Manager{
-		&npmManager{runner: runner},
-		&pipManager{runner: runner, binary: "pip"},
-		&pipManager{runner: runner, binary: "pip3"},
-		&brewManager{runner: runner},
-		&cargoManager{runner: runner},
-		&flatpakManager{runner: runner},
-		&snapManager{runner: runner},
+import (
+	"context"
+)
+
+// DefaultManagers returns the built-in package manager implementations for
+// host, including one npm manager per discovered Node.js version and one pip
+// manager per discovered Python environment. Managers configured as root-only
+// run through host.Sudo, or are skipped when there is none; those in
+// rootDefaults fall back to host.Sudo when they are refused permission.
+func DefaultManagers(host Host, cfg Config) []Manager {
+	// on gives each manager its configured environment, see ScanConfig.Env,
+	// and elevation where it needs root.
+	on := func(name string) Host {
+		h := host
+		runner := host.runner()
+		outer, layered := runner.(outerRunner)
+		if layered {
+			runner = outer.innerRunner()
+		}
+		if host.Sudo != "" {
+			elevated := SudoRunner{Tool: host.Sudo, Inner: runner}
+			switch {
+			case needsRoot(cfg.Scan, name):
+				runner = elevated
+			case mayNeedRoot(cfg.Scan, name):
+				runner = deniedRunner{plain: runner, elevated: elevated}
+			}
+		}
+		if layered {
+			runner = outer.around(runner)
+		}
+		h.Runner = managerRunner(runner, cfg.Scan, name)
+		return h
+	}
+
+	probe := host.probe()
+	managers := []Manager{&npmManager{host: on("npm")}}
+	for _, env := range discoverNodeEnvs(probe, cfg.Node) {
+		managers = append(managers, &npmManager{host: on(env.name), node: env.node, name: env.name})
+	}
+	// Interpreters outside a virtualenv also list the user site, which gets
+	// its own pip:user manager when there is one.
+	pythonEnvs := discoverPythonEnvs(probe, cfg.Python)
+	userSite := false
+	for _, env := range pythonEnvs {
+		userSite = userSite || env.user
 	}
+	managers = append(managers,
+		&pipManager{host: on("pip"), binary: "pip", noUserSite: userSite},
+		&pipManager{host: on("pip3"), binary: "pip3", noUserSite: userSite},
+	)
+	for _, env := range pythonEnvs {
+		managers = append(managers, &pipManager{host: on(env.name), python: env.python, name: env.name, user: env.user, noUserSite: userSite && !env.user})
+	}
+	managers = append(managers,
+		&brewManager{host: on("brew")},
+		&cargoManager{host: on("cargo")},
+		&flatpakManager{host: on("flatpak")},
+		&snapManager{host: on("snap")},
+	)
+
+	if host.Sudo == "" {
+		for i, m := range managers {
+			if needsRoot(cfg.Scan, m.Name()) && !probe.IsRoot() {
+				managers[i] = rootOnly{m}
+			}
+		}
+	}
+	return managers
+}
+
+// outerRunner is a runner layer that belongs above elevation and a
+// manager's environment, so it sees commands as the manager issued them.
+// Recordings are one: bundles must not hold scan.env values, which sudo,
+// containers and ssh pass on the command line.
+type outerRunner interface {
+	CommandRunner
+	innerRunner() CommandRunner
+	// around returns the layer on top of inner instead.
+	around(inner CommandRunner) CommandRunner
 }
 
 // safeRunner ensures managers always have a runner to use.
@@ -23,7 +90,31 @@ func safeRunner(runner CommandRunner) CommandRunner {
 	return ExecRunner{}
 }
 
-// detectBinary wraps exec.LookPath for tests.
-func detectBinary(_ context.Context, binary string) bool {
-	return lookupBinary(binary)
+// detectBinary locates binary on the host's PATH and asks it for its version.
+// A failing version query does not make the manager unavailable.
+func detectBinary(ctx context.Context, host Host, binary string, versionArgs ...string) Detection {
+	det, _ := probeBinary(ctx, host, binary, versionArgs...)
+	return det
+}
+
+// probeBinary is detectBinary that also returns the raw version output for
+// managers that derive more details from it.
+func probeBinary(ctx context.Context, host Host, binary string, versionArgs ...string) (Detection, string) {
+	probe := host.probe()
+	path, err := probe.LookPath(binary)
+	if err != nil {
+		return Detection{Reason: binary + " not found on PATH"}, ""
+	}
+
+	det := Detection{Available: true, Path: path, Scope: installScope(probe, path), Identity: probe.EvalSymlinks(path)}
+	if len(versionArgs) == 0 {
+		versionArgs = []string{"--version"}
+	}
+	res := host.runner().Run(ctx, path, versionArgs...)
+	if res.Error != nil {
+		return det, ""
+	}
+	output := res.CombineOutput()
+	det.Version = firstVersion(output)
+	return det, output
 }
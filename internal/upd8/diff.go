@@ -0,0 +1,282 @@
+package upd8
+
+import (
+	"sort"
+	"time"
+)
+
+// ItemChange describes a package whose outdated state changed between two scans.
+type ItemChange struct {
+	Manager        string `json:"manager"`
+	Item           Item   `json:"item"`
+	PreviousLatest string `json:"previous_latest,omitempty"`
+}
+
+// ErrorChange describes a manager that started or stopped failing between two scans.
+type ErrorChange struct {
+	Manager string `json:"manager"`
+	Error   string `json:"error,omitempty"`
+	Kind    string `json:"kind,omitempty"`
+}
+
+// ScanDiff summarises what changed between two scans.
+type ScanDiff struct {
+	From          ScanRecord    `json:"-"`
+	To            ScanRecord    `json:"-"`
+	NewlyOutdated []ItemChange  `json:"newly_outdated,omitempty"`
+	Bumped        []ItemChange  `json:"bumped,omitempty"`
+	Resolved      []ItemChange  `json:"resolved,omitempty"`
+	NewErrors     []ErrorChange `json:"new_errors,omitempty"`
+	ClearedErrors []ErrorChange `json:"cleared_errors,omitempty"`
+}
+
+// Empty reports whether the two scans are equivalent.
+func (d ScanDiff) Empty() bool {
+	return len(d.NewlyOutdated) == 0 &&
+		len(d.Bumped) == 0 &&
+		len(d.Resolved) == 0 &&
+		len(d.NewErrors) == 0 &&
+		len(d.ClearedErrors) == 0
+}
+
+// DiffScans compares two scans. Package changes are only reported for managers
+// that succeeded in the newer scan; a failing manager reports an error change
+// instead of marking all its packages as resolved. A manager that failed or
+// was skipped in the older scan is compared against the items it still has
+// there, which are none unless from is a Baseline: so nothing that became
+// outdated in the meantime goes unreported.
+func DiffScans(from, to ScanRecord) ScanDiff {
+	d := ScanDiff{From: from, To: to}
+
+	before := make(map[string]ResultRecord, len(from.Results))
+	for _, r := range from.Results {
+		before[r.Key()] = r
+	}
+
+	for _, cur := range to.Results {
+		prev, seen := before[cur.Key()]
+
+		switch {
+		case cur.Skipped != "":
+			// A skipped check says nothing about the packages either way.
+			continue
+		case cur.Error != "" && (!seen || prev.Error == ""):
+			d.NewErrors = append(d.NewErrors, ErrorChange{Manager: cur.Key(), Error: cur.Error, Kind: cur.ErrorKind})
+			continue
+		case cur.Error != "":
+			continue
+		case seen && prev.Error != "":
+			d.ClearedErrors = append(d.ClearedErrors, ErrorChange{Manager: cur.Key(), Error: prev.Error, Kind: prev.ErrorKind})
+		}
+
+		prevItems := make(map[string]Item, len(prev.Items))
+		for _, item := range prev.Items {
+			prevItems[item.Name] = item
+		}
+
+		for _, item := range cur.Items {
+			old, ok := prevItems[item.Name]
+			switch {
+			case !ok:
+				d.NewlyOutdated = append(d.NewlyOutdated, ItemChange{Manager: cur.Key(), Item: item})
+			case old.Latest != item.Latest:
+				d.Bumped = append(d.Bumped, ItemChange{Manager: cur.Key(), Item: item, PreviousLatest: old.Latest})
+			}
+			delete(prevItems, item.Name)
+		}
+
+		for _, item := range prevItems {
+			d.Resolved = append(d.Resolved, ItemChange{Manager: cur.Key(), Item: item})
+		}
+	}
+
+	sortItemChanges(d.NewlyOutdated)
+	sortItemChanges(d.Bumped)
+	sortItemChanges(d.Resolved)
+	return d
+}
+
+// Baseline folds scans, oldest first, into the last of them, in which every
+// manager that failed or was skipped keeps the items of its last successful
+// check. Diffing against it reports what changed since that check.
+func Baseline(scans []ScanRecord) ScanRecord {
+	if len(scans) == 0 {
+		return ScanRecord{}
+	}
+	base := scans[0]
+	for _, rec := range scans[1:] {
+		base = carryForward(base, rec)
+	}
+	return base
+}
+
+// carryForward returns rec with the items of prev copied into the results
+// that failed or were skipped; rec itself is left unchanged.
+func carryForward(prev, rec ScanRecord) ScanRecord {
+	items := make(map[string][]Item, len(prev.Results))
+	for _, r := range prev.Results {
+		items[r.Key()] = r.Items
+	}
+	rec.Results = append([]ResultRecord(nil), rec.Results...)
+	for i, r := range rec.Results {
+		if r.Error != "" || r.Skipped != "" {
+			rec.Results[i].Items = items[r.Key()]
+		}
+	}
+	return rec
+}
+
+func sortItemChanges(changes []ItemChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Manager != changes[j].Manager {
+			return changes[i].Manager < changes[j].Manager
+		}
+		return changes[i].Item.Name < changes[j].Item.Name
+	})
+}
+
+// TransientFilter debounces error changes across consecutive scans, as in
+// watch mode: a network or timeout error is only reported once it has been
+// seen in Threshold scans in a row, so one flaky check raises no alert.
+type TransientFilter struct {
+	// Threshold is the number of consecutive failures needed; values below 2
+	// disable the filter.
+	Threshold int
+
+	streak map[string]int
+	held   map[string]bool
+}
+
+// Apply updates the failure streaks from d.To and rewrites the error changes
+// of d accordingly. It must be called once for every diff, in scan order.
+func (f *TransientFilter) Apply(d *ScanDiff) {
+	if f.Threshold < 2 {
+		return
+	}
+	if f.streak == nil {
+		f.streak = make(map[string]int)
+		f.held = make(map[string]bool)
+	}
+
+	reported := make(map[string]bool)
+	for _, c := range d.NewErrors {
+		reported[c.Manager] = true
+	}
+
+	var late []ErrorChange
+	silent := make(map[string]bool)
+	for _, cur := range d.To.Results {
+		m := cur.Key()
+		transient := cur.ErrorKind == "timeout" || cur.ErrorKind == "network"
+		if transient {
+			f.streak[m]++
+		} else {
+			f.streak[m] = 0
+		}
+
+		switch {
+		case transient && f.streak[m] < f.Threshold:
+			if reported[m] {
+				f.held[m] = true
+				silent[m] = true
+			}
+		case !f.held[m]:
+		case cur.Error == "":
+			// The held-back error cleared up before it was ever announced.
+			delete(f.held, m)
+			silent[m] = true
+		default:
+			// Still failing, or now failing for good: announce it after all.
+			delete(f.held, m)
+			late = append(late, ErrorChange{Manager: m, Error: cur.Error, Kind: cur.ErrorKind})
+		}
+	}
+
+	newErrors := late
+	for _, c := range d.NewErrors {
+		if !silent[c.Manager] {
+			newErrors = append(newErrors, c)
+		}
+	}
+	var cleared []ErrorChange
+	for _, c := range d.ClearedErrors {
+		if !silent[c.Manager] {
+			cleared = append(cleared, c)
+		}
+	}
+	d.NewErrors, d.ClearedErrors = newErrors, cleared
+}
+
+// Quiet reports whether the current failure of the manager with result key
+// key is a network or timeout error that has not yet reached the threshold.
+// It reflects the latest scan passed to Apply.
+func (f *TransientFilter) Quiet(key string) bool {
+	n := f.streak[key]
+	return f.Threshold >= 2 && n > 0 && n < f.Threshold
+}
+
+// Hide returns copies of a scan's record and results in which the failures
+// Quiet holds back are shown as skipped, for rendering a whole watch scan.
+func (f *TransientFilter) Hide(rec ScanRecord, results []Result) (ScanRecord, []Result) {
+	quietSkip := func(kind string) string {
+		return kind + " error; reported if it persists"
+	}
+	rec.Results = append([]ResultRecord(nil), rec.Results...)
+	for i, r := range rec.Results {
+		if r.Error != "" && f.Quiet(r.Key()) {
+			rec.Results[i].Skipped = quietSkip(r.ErrorKind)
+			rec.Results[i].Error, rec.Results[i].ErrorKind, rec.Results[i].ErrorHint, rec.Results[i].Stderr = "", "", "", ""
+		}
+	}
+	results = append([]Result(nil), results...)
+	for i, r := range results {
+		if r.Err != nil && f.Quiet(r.Key()) {
+			results[i].Skipped = quietSkip(ErrorKind(r.Err))
+			results[i].Err = nil
+		}
+	}
+	return rec, results
+}
+
+// ChangeDetector compares consecutive watch scans so that only scans that
+// changed something are reported, plus an optional periodic full summary.
+type ChangeDetector struct {
+	// SummaryEvery asks for a full summary once this much time has passed
+	// since the previous one; zero means only the first scan gets one.
+	SummaryEvery time.Duration
+	// Transient debounces flaky network and timeout errors.
+	Transient TransientFilter
+
+	previous    *ScanRecord
+	lastSummary time.Time
+}
+
+// Next records rec and returns what changed since the previous scan. summary
+// is set for the first scan and whenever a full summary is due, in which case
+// the caller reports the whole scan instead of the diff.
+func (c *ChangeDetector) Next(rec ScanRecord) (diff ScanDiff, summary bool) {
+	if c.previous == nil {
+		diff = ScanDiff{To: rec}
+		summary = true
+	} else {
+		diff = DiffScans(*c.previous, rec)
+		// Allow for the jitter between the watch ticker and scan start times,
+		// so a summary due every 24h is not pushed back by a whole interval.
+		slack := c.SummaryEvery / 100
+		if slack > time.Second {
+			slack = time.Second
+		}
+		summary = c.SummaryEvery > 0 && rec.Time.Sub(c.lastSummary) >= c.SummaryEvery-slack
+	}
+	// The filter still has to see every scan to track failure streaks.
+	c.Transient.Apply(&diff)
+	base := rec
+	if c.previous != nil {
+		base = carryForward(*c.previous, rec)
+	}
+	c.previous = &base
+	if summary {
+		c.lastSummary = rec.Time
+	}
+	return diff, summary
+}
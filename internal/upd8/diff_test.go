@@ -0,0 +1,49 @@
+package upd8
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChangeDetectorAfterError(t *testing.T) {
+	start := time.Date(2025, 3, 14, 9, 0, 0, 0, time.UTC)
+	scan := func(n int, r ResultRecord) ScanRecord {
+		r.Manager = "cargo"
+		return ScanRecord{ID: "scan" + string(rune('0'+n)), Time: start.Add(time.Duration(n) * time.Hour), Results: []ResultRecord{r}}
+	}
+	ripgrep := Item{Name: "ripgrep", Latest: "14.1.1"}
+	bat := Item{Name: "bat", Latest: "0.25.0"}
+	scans := []ScanRecord{
+		scan(0, ResultRecord{Items: []Item{ripgrep}}),
+		scan(1, ResultRecord{Error: "cargo install-update: timed out", ErrorKind: "timeout"}),
+		scan(2, ResultRecord{Items: []Item{bat, ripgrep}}),
+	}
+
+	var c ChangeDetector
+	var diffs []ScanDiff
+	for _, rec := range scans {
+		d, _ := c.Next(rec)
+		diffs = append(diffs, d)
+	}
+	if got := diffs[1].NewErrors; len(got) != 1 {
+		t.Errorf("second scan: new errors = %+v, want the timeout", got)
+	}
+	d := diffs[2]
+	want := []ItemChange{{Manager: "cargo", Item: bat}}
+	if !reflect.DeepEqual(d.NewlyOutdated, want) || len(d.Bumped) != 0 || len(d.Resolved) != 0 {
+		t.Errorf("third scan: newly outdated %+v, bumped %+v, resolved %+v; want only bat new", d.NewlyOutdated, d.Bumped, d.Resolved)
+	}
+	if len(d.ClearedErrors) != 1 {
+		t.Errorf("third scan: cleared errors = %+v, want the timeout", d.ClearedErrors)
+	}
+
+	if got := DiffScans(Baseline(scans[:2]), scans[2]).NewlyOutdated; !reflect.DeepEqual(got, want) {
+		t.Errorf("diff against the baseline: newly outdated = %+v, want %+v", got, want)
+	}
+	// Without a baseline nothing is known from before the failure, so every
+	// item is new rather than none.
+	if got := DiffScans(scans[1], scans[2]).NewlyOutdated; len(got) != 2 {
+		t.Errorf("diff against the failed scan: newly outdated = %+v, want both items", got)
+	}
+}
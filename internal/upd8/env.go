@@ -0,0 +1,162 @@
+package upd8
+
+import (
+	"sort"
+	"strings"
+)
+
+// commandEnv is the environment policy applied to every command ExecRunner
+// starts: the C locale so messages and column headers are not translated, and
+// no colors, pagers, prompts or self-update chatter mixed into parsed output.
+var commandEnv = []string{
+	"LC_ALL=C",
+	"LANG=C",
+	"LANGUAGE=",
+	"TERM=dumb",
+	"NO_COLOR=1",
+	"CLICOLOR=0",
+	"PAGER=cat",
+	"SYSTEMD_PAGER=",
+	"HOMEBREW_NO_AUTO_UPDATE=1",
+	"HOMEBREW_NO_COLOR=1",
+	"HOMEBREW_NO_EMOJI=1",
+	"HOMEBREW_NO_ENV_HINTS=1",
+	"PIP_DISABLE_PIP_VERSION_CHECK=1",
+	"PIP_NO_COLOR=1",
+	"PIP_NO_INPUT=1",
+	"NPM_CONFIG_COLOR=false",
+	"NPM_CONFIG_FUND=false",
+	"NPM_CONFIG_UPDATE_NOTIFIER=false",
+	"CARGO_TERM_COLOR=never",
+}
+
+// strippedEnv lists inherited variables that change the output format of the
+// commands upd8 parses. Names ending in "_" are prefixes. npm reads its
+// settings case-insensitively, so NPM_CONFIG_ names are matched that way.
+var strippedEnv = []string{
+	"LC_",
+	"CLICOLOR_FORCE",
+	"FORCE_COLOR",
+	"PIP_FORMAT",
+	"PIP_UPTODATE",
+	"PIP_NOT_REQUIRED",
+	"PIP_EDITABLE",
+	"PIP_LOCAL",
+	"PIP_USER",
+	"PIP_PATH",
+	"PIP_QUIET",
+	"PIP_VERBOSE",
+	"NPM_CONFIG_JSON",
+	"NPM_CONFIG_PARSEABLE",
+	"NPM_CONFIG_LONG",
+	"NPM_CONFIG_DEPTH",
+	"NPM_CONFIG_ALL",
+	"NPM_CONFIG_UNICODE",
+	"NPM_CONFIG_LOGLEVEL",
+	"NPM_CONFIG_SILENT",
+}
+
+// buildEnv returns base without the variables in strippedEnv, with the
+// commandEnv policy and then extra applied on top. Later entries win.
+func buildEnv(base, extra []string) []string {
+	vars := make(map[string]string, len(base)+len(commandEnv)+len(extra))
+	for _, kv := range base {
+		key, value, _ := strings.Cut(kv, "=")
+		if stripEnv(key) {
+			continue
+		}
+		vars[key] = value
+	}
+	for _, list := range [][]string{commandEnv, extra} {
+		for _, kv := range list {
+			key, value, _ := strings.Cut(kv, "=")
+			vars[key] = value
+		}
+	}
+
+	env := make([]string, 0, len(vars))
+	for key, value := range vars {
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
+
+func stripEnv(key string) bool {
+	upper := strings.ToUpper(key)
+	for _, name := range strippedEnv {
+		if strings.HasPrefix(name, "NPM_CONFIG_") {
+			if upper == name {
+				return true
+			}
+			continue
+		}
+		if key == name || (strings.HasSuffix(name, "_") && strings.HasPrefix(key, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyCommand wraps cmd in env(1) so the environment policy also applies
+// where upd8 cannot set the environment directly, such as on remote hosts and
+// in containers. Stripped prefixes cannot be unset by name; LC_ALL covers LC_.
+func policyCommand(extra []string, cmd string, args []string) []string {
+	words := []string{"env"}
+	for _, name := range strippedEnv {
+		if strings.HasSuffix(name, "_") {
+			continue
+		}
+		words = append(words, "-u", name)
+		if strings.HasPrefix(name, "NPM_CONFIG_") {
+			words = append(words, "-u", strings.ToLower(name))
+		}
+	}
+	words = append(words, commandEnv...)
+	words = append(words, extra...)
+	words = append(words, cmd)
+	return append(words, args...)
+}
+
+// EnvRunner is a CommandRunner that can run commands with extra environment
+// variables, as used for the per-manager "env" config.
+type EnvRunner interface {
+	CommandRunner
+	WithEnv(env []string) CommandRunner
+}
+
+// WithEnv returns a copy of r that also sets env, given as KEY=VALUE pairs.
+func (r ExecRunner) WithEnv(env []string) CommandRunner {
+	r.Env = append(append([]string(nil), r.Env...), env...)
+	return r
+}
+
+// managerRunner returns runner with the configured environment for the named
+// manager. Settings for a base name such as "pip" also apply to discovered
+// managers like "pip:pyenv-3.12.1", which can override them by full name.
+func managerRunner(runner CommandRunner, cfg ScanConfig, name string) CommandRunner {
+	er, ok := runner.(EnvRunner)
+	if !ok || len(cfg.Env) == 0 {
+		return runner
+	}
+
+	var env []string
+	base, _, scoped := strings.Cut(name, ":")
+	if scoped {
+		env = append(env, envPairs(cfg.Env[base])...)
+	}
+	env = append(env, envPairs(cfg.Env[name])...)
+	if len(env) == 0 {
+		return runner
+	}
+	return er.WithEnv(env)
+}
+
+func envPairs(vars map[string]string) []string {
+	pairs := make([]string, 0, len(vars))
+	for key, value := range vars {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
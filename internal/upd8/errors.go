@@ -0,0 +1,255 @@
+package upd8
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Error kinds. A failed check wraps exactly one of these, so callers can tell
+// an offline machine from a broken install with errors.Is.
+var (
+	ErrNotInstalled = errors.New("not installed")
+	ErrPermission   = errors.New("permission denied")
+	ErrTimeout      = errors.New("timed out")
+	ErrNetwork      = errors.New("network unavailable")
+	ErrParse        = errors.New("unexpected output")
+	ErrPrerequisite = errors.New("missing prerequisite")
+)
+
+// errorKinds maps each kind to the name used in JSON output and history.
+var errorKinds = []struct {
+	err  error
+	name string
+}{
+	{ErrNotInstalled, "not_installed"},
+	{ErrPermission, "permission"},
+	{ErrTimeout, "timeout"},
+	{ErrNetwork, "network"},
+	{ErrParse, "parse"},
+	{ErrPrerequisite, "prerequisite"},
+}
+
+// maxStderr bounds how much captured stderr is kept on a CheckError.
+const maxStderr = 4096
+
+// CheckError is a classified manager failure. It carries the stderr of the
+// failing command and, where one is known, a hint on how to fix it.
+type CheckError struct {
+	// Kind is one of the Err* kinds above, or nil when unclassified.
+	Kind error
+	// Op names the failing step, e.g. "snap refresh --list".
+	Op     string
+	Err    error
+	Stderr string
+	Hint   string
+
+	// msg, when set, is the complete message of an error restored from
+	// history or the cache, where Op and the detail are no longer separate.
+	msg string
+}
+
+func (e *CheckError) Error() string {
+	if e.msg != "" {
+		return e.msg
+	}
+	detail := ""
+	if e.Err != nil {
+		detail = e.Err.Error()
+	}
+	if line := lastLine(e.Stderr); line != "" && e.Kind != ErrParse {
+		detail = line
+	}
+	switch {
+	case e.Op == "":
+		return detail
+	case detail == "":
+		return e.Op + " failed"
+	default:
+		return e.Op + ": " + detail
+	}
+}
+
+func (e *CheckError) Unwrap() []error {
+	var errs []error
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// ErrorKind returns a short machine-readable classification of err.
+func ErrorKind(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, k := range errorKinds {
+		if errors.Is(err, k.err) {
+			return k.name
+		}
+	}
+	return "error"
+}
+
+// IsTransient reports whether err is a network or timeout failure that may
+// well succeed when tried again.
+func IsTransient(err error) bool {
+	return errors.Is(err, ErrNetwork) || errors.Is(err, ErrTimeout)
+}
+
+// ErrorHint returns the remediation hint attached to err, if any.
+func ErrorHint(err error) string {
+	var ce *CheckError
+	if errors.As(err, &ce) {
+		return ce.Hint
+	}
+	return ""
+}
+
+// ErrorStderr returns the stderr captured with err, if any.
+func ErrorStderr(err error) string {
+	var ce *CheckError
+	if errors.As(err, &ce) {
+		return ce.Stderr
+	}
+	return ""
+}
+
+// restoreError rebuilds a stored error so that its kind, stderr and hint
+// survive a round trip through history or the result cache. Its message is
+// msg exactly, as the original run printed it.
+func restoreError(msg, kind, stderr, hint string) error {
+	if msg == "" {
+		return nil
+	}
+	ce := &CheckError{Err: errors.New(msg), Hint: hint, Stderr: stderr, msg: msg}
+	for _, k := range errorKinds {
+		if k.name == kind {
+			ce.Kind = k.err
+		}
+	}
+	if ce.Kind == nil && stderr == "" && hint == "" {
+		return ce.Err
+	}
+	return ce
+}
+
+// timeoutError reports that the named check ran out of time, keeping err if it
+// already says so.
+func timeoutError(manager string, after time.Duration, err error) error {
+	if errors.Is(err, ErrTimeout) {
+		return err
+	}
+	return &CheckError{
+		Kind: ErrTimeout,
+		Op:   manager + " check",
+		Err:  fmt.Errorf("no result after %s", after),
+		Hint: fmt.Sprintf("raise its budget with --manager-timeout %s=%s", manager, after*2),
+	}
+}
+
+// Hints holds per-kind remediation advice for one manager. Kinds without an
+// entry fall back to defaultHints.
+type Hints map[error]string
+
+var defaultHints = Hints{
+	ErrNetwork: "check the network connection and any proxy settings, then retry",
+	ErrTimeout: "retry later or raise the budget with --manager-timeout",
+}
+
+// commandError classifies a failed command from its exit code and stderr.
+func commandError(op string, res CommandResult, hints Hints) error {
+	stderr := strings.TrimSpace(string(res.Stderr))
+	if len(stderr) > maxStderr {
+		stderr = "…" + stderr[len(stderr)-maxStderr:]
+	}
+	ce := &CheckError{Op: op, Err: res.Error, Stderr: stderr}
+	if ce.Err == nil {
+		ce.Err = fmt.Errorf("exit status %d", res.ExitCode)
+	}
+	ce.Kind = classify(res, strings.ToLower(stderr))
+	ce.Hint = hints[ce.Kind]
+	if ce.Hint == "" {
+		ce.Hint = defaultHints[ce.Kind]
+	}
+	return ce
+}
+
+// parseError reports output that a manager could not make sense of.
+func parseError(op string, err error, hints Hints) error {
+	hint := hints[ErrParse]
+	if hint == "" {
+		hint = "run `" + op + "` by hand; the installed version may print a format upd8 does not know"
+	}
+	return &CheckError{Kind: ErrParse, Op: "parse " + op + " output", Err: err, Hint: hint}
+}
+
+var (
+	permissionMarkers = []string{
+		"permission denied", "eacces", "operation not permitted", "access denied",
+		"must be run as root", "requires root", "are you root", "needs root", "not permitted",
+		"authentication is required", "host key verification failed", "a password is required",
+	}
+	networkMarkers = []string{
+		"could not resolve", "temporary failure in name resolution", "name or service not known",
+		"network is unreachable", "no route to host", "connection refused", "connection reset",
+		"connection timed out", "timed out while", "failed to establish a new connection",
+		"max retries exceeded", "enotfound", "econnrefused", "econnreset", "etimedout", "eai_again",
+		"unable to access", "could not connect", "couldn't connect", "failed to connect",
+		"tls handshake", "ssl:", "certificate verify failed", "proxy error", "is the network",
+		"network error", "ssh: connect to host", "connection closed by",
+	}
+	notInstalledMarkers = []string{
+		"command not found", "executable file not found", "no such file or directory",
+		"no such container", "no such object",
+	}
+	prerequisiteMarkers = []string{
+		"no such subcommand", "no such command", "unknown command", "unrecognized subcommand",
+		"cannot communicate with server", "is not running", "no remotes found", "no module named pip",
+	}
+)
+
+func classify(res CommandResult, stderr string) error {
+	switch {
+	case errors.Is(res.Error, ErrTimeout):
+		return ErrTimeout
+	case errors.Is(res.Error, exec.ErrNotFound), res.ExitCode == 127:
+		return ErrNotInstalled
+	case res.ExitCode == 126, containsAny(stderr, permissionMarkers):
+		return ErrPermission
+	case containsAny(stderr, networkMarkers):
+		return ErrNetwork
+	case containsAny(stderr, prerequisiteMarkers):
+		return ErrPrerequisite
+	case containsAny(stderr, notInstalledMarkers):
+		return ErrNotInstalled
+	default:
+		return nil
+	}
+}
+
+func containsAny(s string, markers []string) bool {
+	for _, m := range markers {
+		if strings.Contains(s, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// lastLine returns the last non-empty line of s, which is where most tools
+// put the actual error.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package upd8
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WriteJSON writes a scan record as indented JSON.
+func WriteJSON(w io.Writer, rec ScanRecord) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rec)
+}
+
+// WritePrometheus writes a scan record in the Prometheus text exposition
+// format, suitable for the node_exporter textfile collector. history is used
+// for the mean-time-to-update gauge and may be empty.
+func WritePrometheus(w io.Writer, rec ScanRecord, history []ScanRecord) error {
+	var b strings.Builder
+
+	gauge := func(name, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+
+	gauge("upd8_scan_timestamp_seconds", "Unix time the scan started.")
+	fmt.Fprintf(&b, "upd8_scan_timestamp_seconds %d\n", rec.Time.Unix())
+
+	results := append([]ResultRecord(nil), rec.Results...)
+	sort.Slice(results, func(i, j int) bool { return results[i].Key() < results[j].Key() })
+
+	gauge("upd8_manager_up", "Whether the last update check for a manager succeeded.")
+	for _, r := range results {
+		if r.Skipped != "" {
+			continue
+		}
+		up := 1
+		if r.Error != "" {
+			up = 0
+		}
+		fmt.Fprintf(&b, "upd8_manager_up{%s} %d\n", managerLabels(r), up)
+	}
+
+	gauge("upd8_manager_error", "Set to 1 with the error kind when a manager's check failed.")
+	for _, r := range results {
+		if r.Error == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "upd8_manager_error{%s,kind=%s} 1\n", managerLabels(r), promLabel(r.ErrorKind))
+	}
+
+	gauge("upd8_check_duration_seconds", "Time spent checking a manager for updates.")
+	for _, r := range results {
+		fmt.Fprintf(&b, "upd8_check_duration_seconds{%s} %.3f\n", managerLabels(r), float64(r.DurationMs)/1000)
+	}
+
+	gauge("upd8_check_cpu_seconds", "CPU time used by a manager's commands.")
+	for _, r := range results {
+		if r.CPUMs > 0 {
+			fmt.Fprintf(&b, "upd8_check_cpu_seconds{%s} %.3f\n", managerLabels(r), float64(r.CPUMs)/1000)
+		}
+	}
+
+	gauge("upd8_check_max_rss_bytes", "Peak resident memory of a manager's commands.")
+	for _, r := range results {
+		if r.MaxRSSBytes > 0 {
+			fmt.Fprintf(&b, "upd8_check_max_rss_bytes{%s} %d\n", managerLabels(r), r.MaxRSSBytes)
+		}
+	}
+
+	gauge("upd8_metadata_age_seconds", "Age of a manager's local package metadata at scan time.")
+	for _, r := range results {
+		if r.MetadataUpdated != nil {
+			fmt.Fprintf(&b, "upd8_metadata_age_seconds{%s} %.0f\n", managerLabels(r), rec.Time.Sub(*r.MetadataUpdated).Seconds())
+		}
+	}
+
+	gauge("upd8_outdated_packages", "Number of outdated packages reported by a manager.")
+	for _, r := range results {
+		if r.Error != "" || r.Skipped != "" {
+			continue
+		}
+		fmt.Fprintf(&b, "upd8_outdated_packages{%s} %d\n", managerLabels(r), len(r.Items))
+	}
+
+	gauge("upd8_package_outdated_seconds", "How long a package has been outdated according to scan history.")
+	for _, r := range results {
+		for _, item := range r.Items {
+			if item.OutdatedSince == nil {
+				continue
+			}
+			fmt.Fprintf(&b, "upd8_package_outdated_seconds{%s,package=%s,current=%s,latest=%s} %.0f\n",
+				managerLabels(r), promLabel(item.Name), promLabel(item.Current), promLabel(item.Latest),
+				rec.Time.Sub(*item.OutdatedSince).Seconds())
+		}
+	}
+
+	gauge("upd8_package_releases_behind", "Distinct latest versions observed since a package became outdated.")
+	for _, r := range results {
+		for _, item := range r.Items {
+			if item.ReleasesBehind == 0 {
+				continue
+			}
+			fmt.Fprintf(&b, "upd8_package_releases_behind{%s,package=%s} %d\n",
+				managerLabels(r), promLabel(item.Name), item.ReleasesBehind)
+		}
+	}
+
+	if mean, count := MeanTimeToUpdate(history); count > 0 {
+		gauge("upd8_mean_time_to_update_seconds", "Mean time packages stayed outdated before being updated, over recorded history.")
+		fmt.Fprintf(&b, "upd8_mean_time_to_update_seconds %.0f\n", mean.Round(time.Second).Seconds())
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promLabel(v string) string {
+	return `"` + promLabelEscaper.Replace(v) + `"`
+}
+
+// managerLabels identifies a result's manager and, for remote hosts, its host.
+func managerLabels(r ResultRecord) string {
+	if r.Host == "" {
+		return "manager=" + promLabel(r.Manager)
+	}
+	return "host=" + promLabel(r.Host) + ",manager=" + promLabel(r.Manager)
+}
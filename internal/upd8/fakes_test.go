@@ -0,0 +1,56 @@
+package upd8
+
+import (
+	"context"
+	"os"
+	"strings"
+)
+
+// fakeProber is a host with the given executables on its PATH and files,
+// and nothing else.
+type fakeProber struct {
+	paths map[string]string
+	files map[string]FileStat
+}
+
+func (p fakeProber) LookPath(name string) (string, error) {
+	if path, ok := p.paths[name]; ok {
+		return path, nil
+	}
+	return "", os.ErrNotExist
+}
+
+func (p fakeProber) Stat(path string) (FileStat, bool) {
+	st, ok := p.files[path]
+	return st, ok
+}
+
+func (p fakeProber) Getenv(string) string                 { return "" }
+func (p fakeProber) HomeDir() string                      { return "/home/user" }
+func (p fakeProber) OS() string                           { return "linux" }
+func (p fakeProber) Glob(string) []string                 { return nil }
+func (p fakeProber) EvalSymlinks(path string) string      { return path }
+func (p fakeProber) ReadFile(string, int) ([]byte, error) { return nil, os.ErrNotExist }
+func (p fakeProber) IsRoot() bool                         { return false }
+
+// runnerFunc adapts a function to CommandRunner.
+type runnerFunc func(cmd string, args []string) CommandResult
+
+func (f runnerFunc) Run(_ context.Context, cmd string, args ...string) CommandResult {
+	return f(cmd, args)
+}
+
+// envRunner is a fake EnvRunner that hands the extra variables to run.
+type envRunner struct {
+	env []string
+	run func(env []string, argv string) CommandResult
+}
+
+func (r envRunner) Run(_ context.Context, cmd string, args ...string) CommandResult {
+	return r.run(r.env, strings.Join(append([]string{cmd}, args...), " "))
+}
+
+func (r envRunner) WithEnv(env []string) CommandRunner {
+	r.env = append(append([]string(nil), r.env...), env...)
+	return r
+}
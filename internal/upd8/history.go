@@ -0,0 +1,373 @@
+package upd8
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const historyIDLayout = "20060102T150405Z"
+
+// ScanRecord is a persisted snapshot of a single scan.
+type ScanRecord struct {
+	ID         string         `json:"id"`
+	Time       time.Time      `json:"time"`
+	Hostname   string         `json:"hostname"`
+	DurationMs int64          `json:"duration_ms"`
+	Results    []ResultRecord `json:"results"`
+}
+
+// ResultRecord is the serialisable form of a Result.
+type ResultRecord struct {
+	Host            string     `json:"host,omitempty"`
+	Manager         string     `json:"manager"`
+	Items           []Item     `json:"items,omitempty"`
+	UpdateCommand   string     `json:"update_command,omitempty"`
+	Error           string     `json:"error,omitempty"`
+	ErrorKind       string     `json:"error_kind,omitempty"`
+	ErrorHint       string     `json:"error_hint,omitempty"`
+	Skipped         string     `json:"skipped,omitempty"`
+	Stderr          string     `json:"stderr,omitempty"`
+	DurationMs      int64      `json:"duration_ms"`
+	Attempts        int        `json:"attempts,omitempty"`
+	CPUMs           int64      `json:"cpu_ms,omitempty"`
+	MaxRSSBytes     int64      `json:"max_rss_bytes,omitempty"`
+	MetadataUpdated *time.Time `json:"metadata_updated,omitempty"`
+	Refreshed       bool       `json:"refreshed,omitempty"`
+	RefreshError    string     `json:"refresh_error,omitempty"`
+	RefreshMs       int64      `json:"refresh_ms,omitempty"`
+	CachedAt        *time.Time `json:"cached_at,omitempty"`
+	Detection       *Detection `json:"detection,omitempty"`
+}
+
+// NewResultRecord converts a Result into its serialisable form.
+func NewResultRecord(r Result) ResultRecord {
+	rec := ResultRecord{
+		Host:          r.Host,
+		Manager:       r.Manager,
+		Items:         r.Items,
+		UpdateCommand: r.UpdateCommand,
+		DurationMs:    r.DurationMs,
+		Attempts:      r.Attempts,
+		Skipped:       r.Skipped,
+		CPUMs:         r.Usage.CPU.Milliseconds(),
+		MaxRSSBytes:   r.Usage.MaxRSS,
+	}
+	if r.Err != nil {
+		rec.Error = r.Err.Error()
+		rec.ErrorKind = ErrorKind(r.Err)
+		rec.ErrorHint = ErrorHint(r.Err)
+		rec.Stderr = ErrorStderr(r.Err)
+	}
+	if !r.CachedAt.IsZero() {
+		cachedAt := r.CachedAt.UTC()
+		rec.CachedAt = &cachedAt
+	}
+	if !r.MetadataUpdated.IsZero() {
+		updated := r.MetadataUpdated.UTC()
+		rec.MetadataUpdated = &updated
+	}
+	if r.Refreshed {
+		rec.Refreshed = true
+		rec.RefreshMs = r.RefreshMs
+		if r.RefreshErr != nil {
+			rec.RefreshError = r.RefreshErr.Error()
+		}
+	}
+	if r.Detection.Available {
+		det := r.Detection
+		rec.Detection = &det
+	}
+	return rec
+}
+
+// Key identifies the manager across scans and hosts, see Result.Key.
+func (rec ResultRecord) Key() string {
+	return resultKey(rec.Host, rec.Manager)
+}
+
+// Result converts the record back into a Result for rendering.
+func (rec ResultRecord) Result() Result {
+	r := Result{
+		Host:          rec.Host,
+		Manager:       rec.Manager,
+		Items:         rec.Items,
+		UpdateCommand: rec.UpdateCommand,
+		DurationMs:    rec.DurationMs,
+		Attempts:      rec.Attempts,
+		Skipped:       rec.Skipped,
+		Usage: Usage{
+			CPU:    time.Duration(rec.CPUMs) * time.Millisecond,
+			MaxRSS: rec.MaxRSSBytes,
+		},
+		Err: restoreError(rec.Error, rec.ErrorKind, rec.Stderr, rec.ErrorHint),
+	}
+	if rec.CachedAt != nil {
+		r.CachedAt = *rec.CachedAt
+	}
+	if rec.MetadataUpdated != nil {
+		r.MetadataUpdated = *rec.MetadataUpdated
+	}
+	r.Refreshed = rec.Refreshed
+	r.RefreshMs = rec.RefreshMs
+	if rec.RefreshError != "" {
+		r.RefreshErr = errors.New(rec.RefreshError)
+	}
+	if rec.Detection != nil {
+		r.Detection = *rec.Detection
+	}
+	return r
+}
+
+// NewScanRecord builds a record for a scan that started at start.
+func NewScanRecord(start time.Time, results []Result) ScanRecord {
+	hostname, _ := os.Hostname()
+	rec := ScanRecord{
+		Time:       start.UTC(),
+		Hostname:   hostname,
+		DurationMs: time.Since(start).Milliseconds(),
+		Results:    make([]ResultRecord, 0, len(results)),
+	}
+	for _, r := range results {
+		rec.Results = append(rec.Results, NewResultRecord(r))
+	}
+	return rec
+}
+
+// Outdated returns the total number of outdated items in the scan.
+func (rec ScanRecord) Outdated() int {
+	total := 0
+	for _, r := range rec.Results {
+		total += len(r.Items)
+	}
+	return total
+}
+
+// Errors returns the number of managers that failed during the scan.
+func (rec ScanRecord) Errors() int {
+	total := 0
+	for _, r := range rec.Results {
+		if r.Error != "" {
+			total++
+		}
+	}
+	return total
+}
+
+// HistoryStore persists scan records as one JSON file per scan.
+type HistoryStore struct {
+	Dir    string
+	Keep   int
+	MaxAge time.Duration
+}
+
+// NewHistoryStore returns a store under StateDir using the given retention settings.
+func NewHistoryStore(cfg HistoryConfig) HistoryStore {
+	return HistoryStore{
+		Dir:    filepath.Join(StateDir(), "scans"),
+		Keep:   cfg.Keep,
+		MaxAge: time.Duration(cfg.MaxAge),
+	}
+}
+
+// Save writes rec to disk, assigning it an ID, and applies retention.
+func (s HistoryStore) Save(rec *ScanRecord) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("create history dir: %w", err)
+	}
+
+	// Scans started in the same second are numbered after the latest one
+	// still stored, so an ID freed by Prune is never handed out again.
+	base := rec.Time.UTC().Format(historyIDLayout)
+	ids, err := s.IDs()
+	if err != nil {
+		return err
+	}
+	last := 0
+	for _, id := range ids {
+		if b, n := splitHistoryID(id); b == base && n > last {
+			last = n
+		}
+	}
+	rec.ID = base
+	if last > 0 {
+		rec.ID = fmt.Sprintf("%s-%d", base, last+1)
+	}
+
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode scan record: %w", err)
+	}
+
+	tmp := s.path(rec.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write scan record: %w", err)
+	}
+	if err := os.Rename(tmp, s.path(rec.ID)); err != nil {
+		return fmt.Errorf("write scan record: %w", err)
+	}
+
+	return s.Prune(time.Now())
+}
+
+// IDs returns the stored scan IDs, oldest first.
+func (s HistoryStore) IDs() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read history dir: %w", err)
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(name, ".json"))
+	}
+	sort.Slice(ids, func(i, j int) bool { return historyIDLess(ids[i], ids[j]) })
+	return ids, nil
+}
+
+// historyIDLess orders scan IDs by time, then by the "-N" suffix Save gives
+// scans started in the same second. Plain string order would put "-10"
+// before "-2".
+func historyIDLess(a, b string) bool {
+	baseA, nA := splitHistoryID(a)
+	baseB, nB := splitHistoryID(b)
+	if baseA != baseB {
+		return baseA < baseB
+	}
+	return nA < nB
+}
+
+// splitHistoryID returns the timestamp part of id and its suffix number,
+// which is 1 for the first scan of a second.
+func splitHistoryID(id string) (string, int) {
+	base, suffix, ok := strings.Cut(id, "-")
+	if !ok {
+		return id, 1
+	}
+	n, err := strconv.Atoi(suffix)
+	if err != nil {
+		return id, 0
+	}
+	return base, n
+}
+
+// List loads every stored scan, oldest first. Unreadable records are skipped.
+func (s HistoryStore) List() ([]ScanRecord, error) {
+	return s.Recent(0)
+}
+
+// Recent loads the n most recent scans (all when n <= 0), oldest first.
+// Unreadable records are skipped.
+func (s HistoryStore) Recent(n int) ([]ScanRecord, error) {
+	ids, err := s.IDs()
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 && len(ids) > n {
+		ids = ids[len(ids)-n:]
+	}
+
+	records := make([]ScanRecord, 0, len(ids))
+	for _, id := range ids {
+		rec, err := s.Load(id)
+		if err != nil {
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// Load reads a scan by ID. A unique ID prefix is also accepted.
+func (s HistoryStore) Load(id string) (ScanRecord, error) {
+	var rec ScanRecord
+
+	resolved, err := s.resolve(id)
+	if err != nil {
+		return rec, err
+	}
+
+	data, err := os.ReadFile(s.path(resolved))
+	if err != nil {
+		return rec, fmt.Errorf("read scan %s: %w", resolved, err)
+	}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return rec, fmt.Errorf("parse scan %s: %w", resolved, err)
+	}
+	return rec, nil
+}
+
+// Prune removes records beyond the Keep limit or older than MaxAge.
+func (s HistoryStore) Prune(now time.Time) error {
+	ids, err := s.IDs()
+	if err != nil {
+		return err
+	}
+
+	var remove []string
+	if s.Keep > 0 && len(ids) > s.Keep {
+		remove = append(remove, ids[:len(ids)-s.Keep]...)
+		ids = ids[len(ids)-s.Keep:]
+	}
+	if s.MaxAge > 0 {
+		cutoff := now.Add(-s.MaxAge)
+		for _, id := range ids {
+			ts, err := time.Parse(historyIDLayout, strings.SplitN(id, "-", 2)[0])
+			if err != nil || !ts.Before(cutoff) {
+				continue
+			}
+			remove = append(remove, id)
+		}
+	}
+
+	for _, id := range remove {
+		if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("prune scan %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func (s HistoryStore) resolve(id string) (string, error) {
+	if _, err := os.Stat(s.path(id)); err == nil {
+		return id, nil
+	}
+
+	ids, err := s.IDs()
+	if err != nil {
+		return "", err
+	}
+
+	var matches []string
+	for _, candidate := range ids {
+		if strings.HasPrefix(candidate, id) {
+			matches = append(matches, candidate)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no scan with id %q", id)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("scan id %q is ambiguous (%d matches)", id, len(matches))
+	}
+}
+
+func (s HistoryStore) path(id string) string {
+	return filepath.Join(s.Dir, id+".json")
+}
@@ -0,0 +1,42 @@
+package upd8
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestHistoryIDsInScanOrder(t *testing.T) {
+	store := HistoryStore{Dir: t.TempDir(), Keep: 10}
+	start := time.Date(2025, 3, 14, 9, 0, 0, 0, time.UTC)
+
+	// Twelve scans within one second, as rescans in watch mode can do.
+	var saved []string
+	for i := 0; i < 12; i++ {
+		rec := ScanRecord{Time: start.Add(time.Duration(i) * time.Millisecond)}
+		if err := store.Save(&rec); err != nil {
+			t.Fatal(err)
+		}
+		saved = append(saved, rec.ID)
+	}
+	if saved[1] != "20250314T090000Z-2" || saved[11] != "20250314T090000Z-12" {
+		t.Fatalf("saved IDs %v", saved)
+	}
+
+	ids, err := store.IDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keep pruned the two oldest scans, not "-10" and "-11".
+	if want := saved[2:]; !slices.Equal(ids, want) {
+		t.Errorf("IDs = %v, want %v", ids, want)
+	}
+
+	recent, err := store.Recent(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 1 || recent[0].ID != saved[11] {
+		t.Errorf("Recent(1) = %v, want %s", recent, saved[11])
+	}
+}
@@ -0,0 +1,271 @@
+package upd8
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Host is the machine whose package managers are checked. Managers run
+// commands through Runner and inspect files through Probe, never through the
+// os package directly, so detection and discovery work on remote machines.
+type Host struct {
+	// Name labels results from this host; empty for the local machine.
+	Name   string
+	Runner CommandRunner
+	Probe  Prober
+	// Sudo is the command ("sudo" or "doas") used to run managers that need
+	// root; empty when they cannot be elevated. See PrepareSudo.
+	Sudo string
+}
+
+// LocalHost returns the machine upd8 runs on, with commands run by runner.
+func LocalHost(runner CommandRunner) Host {
+	return Host{Runner: runner, Probe: LocalProber{}}
+}
+
+func (h Host) runner() CommandRunner {
+	return safeRunner(h.Runner)
+}
+
+// IsRoot reports whether commands on h run as root without elevation.
+func (h Host) IsRoot() bool {
+	return h.probe().IsRoot()
+}
+
+func (h Host) probe() Prober {
+	if h.Probe == nil {
+		return LocalProber{}
+	}
+	return h.Probe
+}
+
+// FileStat is the subset of file information managers need.
+type FileStat struct {
+	IsDir   bool      `json:"is_dir,omitempty"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Prober answers questions about a host's filesystem and environment.
+// Lookups that fail behave like missing files.
+type Prober interface {
+	// LookPath finds an executable on the host's PATH.
+	LookPath(name string) (string, error)
+	Getenv(key string) string
+	HomeDir() string
+	// OS returns the host's operating system in runtime.GOOS terms.
+	OS() string
+	// Glob returns the sorted paths matching pattern; only the * and ?
+	// wildcards are used.
+	Glob(pattern string) []string
+	Stat(path string) (FileStat, bool)
+	// EvalSymlinks returns path with symlinks resolved, or path on failure.
+	EvalSymlinks(path string) string
+	// ReadFile returns up to limit bytes from the start of a file.
+	ReadFile(path string, limit int) ([]byte, error)
+	// IsRoot reports whether commands on the host run as root.
+	IsRoot() bool
+}
+
+// LocalProber inspects the local machine.
+type LocalProber struct{}
+
+func (LocalProber) LookPath(name string) (string, error) { return exec.LookPath(name) }
+func (LocalProber) Getenv(key string) string             { return os.Getenv(key) }
+func (LocalProber) OS() string                           { return runtime.GOOS }
+
+func (LocalProber) IsRoot() bool { return os.Geteuid() == 0 }
+
+func (LocalProber) HomeDir() string {
+	home, _ := os.UserHomeDir()
+	return home
+}
+
+func (LocalProber) Glob(pattern string) []string {
+	matches, _ := filepath.Glob(pattern)
+	sort.Strings(matches)
+	return matches
+}
+
+func (LocalProber) Stat(path string) (FileStat, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return FileStat{}, false
+	}
+	return FileStat{IsDir: info.IsDir(), ModTime: info.ModTime()}, true
+}
+
+func (LocalProber) EvalSymlinks(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
+
+func (LocalProber) ReadFile(path string, limit int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, int64(limit)))
+}
+
+// probeTimeout bounds a single ShellProber query.
+const probeTimeout = 15 * time.Second
+
+// ShellProber inspects a host by running POSIX shell snippets through a
+// runner, for hosts reached over SSH or inside containers. Home directory and
+// OS are looked up once and remembered.
+type ShellProber struct {
+	Runner CommandRunner
+	// Ctx bounds every query on top of probeTimeout. The Prober methods take
+	// no context, so this is how cancelling a scan (Ctrl+C) stops detection
+	// right away instead of after the remaining queries time out.
+	Ctx context.Context
+
+	homeOnce, osOnce, uidOnce sync.Once
+	home, goos, uid           string
+}
+
+// NewShellProber returns a prober that runs its queries through runner for
+// as long as ctx is not cancelled.
+func NewShellProber(ctx context.Context, runner CommandRunner) *ShellProber {
+	return &ShellProber{Runner: runner, Ctx: ctx}
+}
+
+func (p *ShellProber) sh(script string, args ...string) (string, bool) {
+	parent := p.Ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	if parent.Err() != nil {
+		return "", false
+	}
+	ctx, cancel := context.WithTimeout(parent, probeTimeout)
+	defer cancel()
+	res := safeRunner(p.Runner).Run(ctx, "sh", append([]string{"-c", script, "sh"}, args...)...)
+	if res.Error != nil {
+		return "", false
+	}
+	return strings.TrimRight(string(res.Stdout), "\n"), true
+}
+
+func (p *ShellProber) LookPath(name string) (string, error) {
+	out, ok := p.sh(`command -v "$1"`, name)
+	// Builtins, aliases and functions are reported without a path.
+	if !ok || !strings.HasPrefix(out, "/") {
+		return "", fmt.Errorf("%s: %w", name, exec.ErrNotFound)
+	}
+	return out, nil
+}
+
+func (p *ShellProber) Getenv(key string) string {
+	out, _ := p.sh(`printenv "$1"`, key)
+	return out
+}
+
+func (p *ShellProber) HomeDir() string {
+	p.homeOnce.Do(func() { p.home, _ = p.sh(`printf '%s\n' "$HOME"`) })
+	return p.home
+}
+
+func (p *ShellProber) OS() string {
+	p.osOnce.Do(func() {
+		out, _ := p.sh(`uname -s`)
+		p.goos = strings.ToLower(strings.TrimSpace(out))
+	})
+	return p.goos
+}
+
+func (p *ShellProber) IsRoot() bool {
+	p.uidOnce.Do(func() { p.uid, _ = p.sh(`id -u`) })
+	return strings.TrimSpace(p.uid) == "0"
+}
+
+func (p *ShellProber) Glob(pattern string) []string {
+	script := `for f in ` + globQuote(pattern) + `; do [ -e "$f" ] && printf '%s\n' "$f"; done; true`
+	out, ok := p.sh(script)
+	if !ok || out == "" {
+		return nil
+	}
+	matches := strings.Split(out, "\n")
+	sort.Strings(matches)
+	return matches
+}
+
+func (p *ShellProber) Stat(path string) (FileStat, bool) {
+	// GNU stat first, then the BSD/macOS flavour.
+	out, ok := p.sh(`stat -L -c '%Y %F' "$1" 2>/dev/null || stat -L -f '%m %HT' "$1"`, path)
+	if !ok {
+		return FileStat{}, false
+	}
+	secs, kind, _ := strings.Cut(strings.TrimSpace(out), " ")
+	n, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return FileStat{}, false
+	}
+	return FileStat{
+		IsDir:   strings.EqualFold(kind, "directory"),
+		ModTime: time.Unix(n, 0),
+	}, true
+}
+
+func (p *ShellProber) EvalSymlinks(path string) string {
+	out, ok := p.sh(`readlink -f "$1" 2>/dev/null || realpath "$1"`, path)
+	if !ok || out == "" {
+		return path
+	}
+	return out
+}
+
+func (p *ShellProber) ReadFile(path string, limit int) ([]byte, error) {
+	out, ok := p.sh(`head -c "$2" "$1"`, path, strconv.Itoa(limit))
+	if !ok {
+		return nil, errors.New("cannot read " + path)
+	}
+	return []byte(out), nil
+}
+
+// globQuote quotes pattern for the shell while leaving its wildcards active.
+func globQuote(pattern string) string {
+	var b strings.Builder
+	literal := func(s string) {
+		if s != "" {
+			b.WriteString(shellQuote(s))
+		}
+	}
+	start := 0
+	for i, r := range pattern {
+		if r == '*' || r == '?' {
+			literal(pattern[start:i])
+			b.WriteRune(r)
+			start = i + 1
+		}
+	}
+	literal(pattern[start:])
+	return b.String()
+}
+
+// shellQuote quotes s as a single POSIX shell word.
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:@,+%", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -4,28 +4,96 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
+	"path/filepath"
 	"sort"
+	"time"
 )
 
 type brewManager struct {
-	runner CommandRunner
+	host Host
 }
 
 func (m *brewManager) Name() string { return "brew" }
 
-func (m *brewManager) Detect(ctx context.Context) bool {
-	_ = ctx
-	return lookupBinary("brew")
+func (m *brewManager) Detect(ctx context.Context) Detection {
+	return detectBinary(ctx, m.host, "brew")
+}
+
+// CheckOffline is the regular check: with HOMEBREW_NO_AUTO_UPDATE set (see
+// commandEnv) brew outdated only reads the local formula and cask data.
+func (m *brewManager) CheckOffline(ctx context.Context) Result {
+	return m.CheckUpdates(ctx)
+}
+
+// Refresh runs `brew update`, which brew outdated otherwise only does on its
+// own schedule (and never here, see HOMEBREW_NO_AUTO_UPDATE in commandEnv).
+func (m *brewManager) Refresh(ctx context.Context) error {
+	res := m.host.runner().Run(ctx, "brew", "update", "--quiet")
+	if res.Error != nil && res.ExitCode != 0 {
+		return commandError("brew update", res, brewHints)
+	}
+	return nil
+}
+
+// Upgrade runs `brew upgrade` for pkgs.
+func (m *brewManager) Upgrade(ctx context.Context, pkgs []string) error {
+	res := m.host.runner().Run(ctx, "brew", append([]string{"upgrade"}, pkgs...)...)
+	if res.Error != nil && res.ExitCode != 0 {
+		return commandError("brew upgrade", res, brewHints)
+	}
+	return nil
+}
+
+// MetadataUpdated checks the downloaded formula and cask API files and, for
+// taps installed from git, the core repository's last fetch.
+func (m *brewManager) MetadataUpdated() time.Time {
+	probe := m.host.probe()
+	var paths []string
+	if cache := brewCacheDir(probe); cache != "" {
+		paths = append(paths,
+			filepath.Join(cache, "api", "formula.jws.json"),
+			filepath.Join(cache, "api", "cask.jws.json"),
+		)
+	}
+	if brew, err := probe.LookPath("brew"); err == nil {
+		repo := filepath.Dir(filepath.Dir(probe.EvalSymlinks(brew)))
+		paths = append(paths, filepath.Join(repo, ".git", "FETCH_HEAD"))
+	}
+	return newestModTime(probe, paths...)
+}
+
+// brewCacheDir mirrors Homebrew's choice of HOMEBREW_CACHE.
+func brewCacheDir(p Prober) string {
+	if dir := p.Getenv("HOMEBREW_CACHE"); dir != "" {
+		return dir
+	}
+	home := p.HomeDir()
+	if home == "" {
+		return ""
+	}
+	if p.OS() == "darwin" {
+		return filepath.Join(home, "Library", "Caches", "Homebrew")
+	}
+	if dir := p.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "Homebrew")
+	}
+	return filepath.Join(home, ".cache", "Homebrew")
+}
+
+var brewHints = Hints{
+	ErrPermission:   "fix ownership of the Homebrew prefix: `sudo chown -R $(whoami) $(brew --prefix)/*`",
+	ErrNetwork:      "Homebrew could not reach its API; check the network or set HOMEBREW_NO_INSTALL_FROM_API=1",
+	ErrPrerequisite: "run `brew doctor` and follow its advice",
 }
 
 func (m *brewManager) CheckUpdates(ctx context.Context) Result {
 	r := Result{Manager: m.Name(), UpdateCommand: "brew upgrade"}
-	runner := safeRunner(m.runner)
+	runner := m.host.runner()
 
 	cmdRes := runner.Run(ctx, "brew", "outdated", "--json=v2")
+	r.Usage = cmdRes.Usage
 	if cmdRes.Error != nil && cmdRes.ExitCode != 0 {
-		r.Err = fmt.Errorf("brew outdated failed: %w", cmdRes.Error)
+		r.Err = commandError("brew outdated", cmdRes, brewHints)
 		return r
 	}
 
@@ -48,7 +116,7 @@ func (m *brewManager) CheckUpdates(ctx context.Context) Result {
 	}
 
 	if err := json.Unmarshal(payload, &parsed); err != nil {
-		r.Err = fmt.Errorf("parse brew outdated output: %w", err)
+		r.Err = parseError("brew outdated --json=v2", err, brewHints)
 		return r
 	}
 
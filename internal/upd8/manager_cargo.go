@@ -3,36 +3,47 @@ package upd8
 import (
 	"bytes"
 	"context"
-	"fmt"
 	"regexp"
 	"sort"
 	"strings"
 )
 
 type cargoManager struct {
-	runner CommandRunner
+	host Host
 }
 
 func (m *cargoManager) Name() string { return "cargo" }
 
-func (m *cargoManager) Detect(ctx context.Context) bool {
-	_ = ctx
-	if !lookupBinary("cargo") {
-		return false
+func (m *cargoManager) Detect(ctx context.Context) Detection {
+	det := detectBinary(ctx, m.host, "cargo")
+	if !det.Available {
+		return det
 	}
 	// cargo install-update is provided by the cargo-update crate.
-	return lookupBinary("cargo-install-update")
+	if !lookupBinary(m.host.probe(), "cargo-install-update") {
+		det.Available = false
+		det.Reason = "cargo-install-update not found (install it with `cargo install cargo-update`)"
+	}
+	return det
 }
 
 var cargoLineRegex = regexp.MustCompile(`(?P<name>[^\s]+)\s+v?(?P<current>[0-9][^\s]*)\s+->\s+v?(?P<latest>[0-9][^\s]*)`)
 
+var cargoHints = Hints{
+	ErrPrerequisite: "install cargo-update: `cargo install cargo-update`",
+	ErrNotInstalled: "install cargo-update: `cargo install cargo-update`",
+	ErrNetwork:      "cargo could not fetch the crates.io index; check the network or registry settings",
+	ErrPermission:   "make sure $CARGO_HOME is writable by the current user",
+}
+
 func (m *cargoManager) CheckUpdates(ctx context.Context) Result {
 	r := Result{Manager: m.Name(), UpdateCommand: "cargo install-update -a"}
-	runner := safeRunner(m.runner)
+	runner := m.host.runner()
 
 	cmdRes := runner.Run(ctx, "cargo", "install-update", "--list")
+	r.Usage = cmdRes.Usage
 	if cmdRes.Error != nil && cmdRes.ExitCode != 0 {
-		r.Err = fmt.Errorf("cargo install-update --list failed: %w", cmdRes.Error)
+		r.Err = commandError("cargo install-update --list", cmdRes, cargoHints)
 		return r
 	}
 
@@ -66,3 +77,12 @@ func (m *cargoManager) CheckUpdates(ctx context.Context) Result {
 	sort.Slice(r.Items, func(i, j int) bool { return r.Items[i].Name < r.Items[j].Name })
 	return r
 }
+
+// Upgrade runs `cargo install-update` for pkgs.
+func (m *cargoManager) Upgrade(ctx context.Context, pkgs []string) error {
+	res := m.host.runner().Run(ctx, "cargo", append([]string{"install-update"}, pkgs...)...)
+	if res.Error != nil && res.ExitCode != 0 {
+		return commandError("cargo install-update", res, cargoHints)
+	}
+	return nil
+}
@@ -3,29 +3,80 @@ package upd8
 import (
 	"bytes"
 	"context"
-	"fmt"
+	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 )
 
 type flatpakManager struct {
-	runner CommandRunner
+	host Host
 }
 
 func (m *flatpakManager) Name() string { return "flatpak" }
 
-func (m *flatpakManager) Detect(ctx context.Context) bool {
-	_ = ctx
-	return lookupBinary("flatpak")
+func (m *flatpakManager) Detect(ctx context.Context) Detection {
+	return detectBinary(ctx, m.host, "flatpak")
+}
+
+// Refresh downloads the appstream data of every configured remote.
+func (m *flatpakManager) Refresh(ctx context.Context) error {
+	res := m.host.runner().Run(ctx, "flatpak", "update", "--appstream", "--noninteractive")
+	if res.Error != nil && res.ExitCode != 0 {
+		return commandError("flatpak update --appstream", res, flatpakHints)
+	}
+	return nil
+}
+
+// Upgrade updates the refs in pkgs without prompting.
+func (m *flatpakManager) Upgrade(ctx context.Context, pkgs []string) error {
+	args := append([]string{"update", "--noninteractive"}, pkgs...)
+	res := m.host.runner().Run(ctx, "flatpak", args...)
+	if res.Error != nil && res.ExitCode != 0 {
+		return commandError("flatpak update", res, flatpakHints)
+	}
+	return nil
+}
+
+// MetadataUpdated returns the age of the stalest remote's appstream data in
+// the system and user installations.
+func (m *flatpakManager) MetadataUpdated() time.Time {
+	probe := m.host.probe()
+	var stamps []string
+	dirs := []string{"/var/lib/flatpak"}
+	if home := probe.HomeDir(); home != "" {
+		dirs = append(dirs, filepath.Join(home, ".local", "share", "flatpak"))
+	}
+	for _, dir := range dirs {
+		stamps = append(stamps, probe.Glob(filepath.Join(dir, "appstream", "*", "*", ".timestamp"))...)
+	}
+	return oldestModTime(probe, stamps...)
+}
+
+var flatpakHints = Hints{
+	ErrPermission:   "system installations may need root: run `sudo flatpak remote-ls --updates`",
+	ErrNetwork:      "flatpak could not reach its remotes; check the network or `flatpak remotes`",
+	ErrPrerequisite: "add a remote first, e.g. `flatpak remote-add --if-not-exists flathub https://dl.flathub.org/repo/flathub.flatpakrepo`",
 }
 
 func (m *flatpakManager) CheckUpdates(ctx context.Context) Result {
+	return m.check(ctx)
+}
+
+// CheckOffline lists updates from the remote metadata flatpak already has.
+func (m *flatpakManager) CheckOffline(ctx context.Context) Result {
+	return m.check(ctx, "--cached")
+}
+
+func (m *flatpakManager) check(ctx context.Context, extraArgs ...string) Result {
 	r := Result{Manager: m.Name(), UpdateCommand: "flatpak update"}
-	runner := safeRunner(m.runner)
+	runner := m.host.runner()
 
-	cmdRes := runner.Run(ctx, "flatpak", "remote-ls", "--updates", "--columns=ref,version")
+	args := append([]string{"remote-ls", "--updates", "--columns=ref,version"}, extraArgs...)
+	cmdRes := runner.Run(ctx, "flatpak", args...)
+	r.Usage = cmdRes.Usage
 	if cmdRes.Error != nil && cmdRes.ExitCode != 0 {
-		r.Err = fmt.Errorf("flatpak remote-ls failed: %w", cmdRes.Error)
+		r.Err = commandError("flatpak remote-ls --updates", cmdRes, flatpakHints)
 		return r
 	}
 
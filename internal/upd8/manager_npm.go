@@ -5,27 +5,103 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"path/filepath"
 	"sort"
 )
 
 type npmManager struct {
-	runner CommandRunner
+	host Host
+	// node, when set, runs the npm bundled with that node binary instead of
+	// the npm on PATH, so each installed Node version is checked separately.
+	node string
+	// name overrides the manager name for discovered installations.
+	name string
 }
 
-func (m *npmManager) Name() string { return "npm" }
+func (m *npmManager) Name() string {
+	if m.name != "" {
+		return m.name
+	}
+	return "npm"
+}
+
+// command returns the executable and leading arguments that invoke npm.
+func (m *npmManager) command() (string, []string) {
+	if m.node != "" {
+		return m.node, []string{npmCLI(m.host.probe(), m.node)}
+	}
+	return "npm", nil
+}
+
+func (m *npmManager) Detect(ctx context.Context) Detection {
+	if m.node != "" {
+		return m.detectNode(ctx)
+	}
 
-func (m *npmManager) Detect(ctx context.Context) bool {
-	_ = ctx
-	return lookupBinary("npm")
+	det := detectBinary(ctx, m.host, "npm")
+	if !det.Available {
+		return det
+	}
+
+	// npm on PATH is usually a symlink into <prefix>/lib/node_modules/npm,
+	// which lets it be merged with the same installation found via nvm & co.
+	if cli := m.host.probe().EvalSymlinks(det.Path); filepath.Base(cli) == "npm-cli.js" {
+		det.Identity = "npm-prefix:" + filepath.Dir(filepath.Dir(filepath.Dir(filepath.Dir(filepath.Dir(cli)))))
+	}
+	if node := detectBinary(ctx, m.host, "node"); node.Version != "" {
+		det.Label = "node " + node.Version
+		det.Interpreter = node.Path
+	}
+	return det
+}
+
+// detectNode checks a discovered node binary and its bundled npm.
+func (m *npmManager) detectNode(ctx context.Context) Detection {
+	probe := m.host.probe()
+	if !isFile(probe, m.node) {
+		return Detection{Reason: m.node + " not found"}
+	}
+	cli := npmCLI(probe, m.node)
+	if !isFile(probe, cli) {
+		return Detection{Reason: "no bundled npm for " + m.node}
+	}
+
+	det := Detection{
+		Available:   true,
+		Path:        cli,
+		Scope:       installScope(probe, m.node),
+		Identity:    "npm-prefix:" + nodePrefix(probe, m.node),
+		Interpreter: m.node,
+	}
+
+	runner := m.host.runner()
+	if res := runner.Run(ctx, m.node, "--version"); res.Error == nil {
+		det.Label = "node " + firstVersion(res.CombineOutput())
+	}
+	if res := runner.Run(ctx, m.node, cli, "--version"); res.Error == nil {
+		det.Version = firstVersion(res.CombineOutput())
+	}
+	return det
+}
+
+var npmHints = Hints{
+	ErrPermission: "the global prefix is not writable; set a user prefix with `npm config set prefix ~/.npm-global`",
+	ErrNetwork:    "npm could not reach the registry; check the network, `npm config get registry` and proxy settings",
 }
 
 func (m *npmManager) CheckUpdates(ctx context.Context) Result {
+	bin, prefix := m.command()
 	r := Result{Manager: m.Name(), UpdateCommand: "npm update -g"}
-	runner := safeRunner(m.runner)
+	if m.node != "" {
+		r.UpdateCommand = fmt.Sprintf("PATH=%s:$PATH npm update -g", filepath.Dir(m.node))
+	}
+	runner := m.host.runner()
 
-	cmdRes := runner.Run(ctx, "npm", "outdated", "-g", "--json")
+	args := append(append([]string{}, prefix...), "outdated", "-g", "--json")
+	cmdRes := runner.Run(ctx, bin, args...)
+	r.Usage = cmdRes.Usage
 	if cmdRes.Error != nil && cmdRes.ExitCode != 0 && cmdRes.ExitCode != 1 {
-		r.Err = fmt.Errorf("npm outdated failed: %w", cmdRes.Error)
+		r.Err = commandError("npm outdated -g", cmdRes, npmHints)
 		return r
 	}
 
@@ -42,7 +118,7 @@ func (m *npmManager) CheckUpdates(ctx context.Context) Result {
 
 	entries := map[string]npmEntry{}
 	if err := json.Unmarshal(payload, &entries); err != nil {
-		r.Err = fmt.Errorf("parse npm outdated output: %w", err)
+		r.Err = parseError("npm outdated -g --json", err, npmHints)
 		return r
 	}
 
@@ -68,3 +144,18 @@ func (m *npmManager) CheckUpdates(ctx context.Context) Result {
 
 	return r
 }
+
+// Upgrade installs the latest version of each of pkgs globally. Unlike
+// `npm update -g` this crosses major versions, as the check reports them.
+func (m *npmManager) Upgrade(ctx context.Context, pkgs []string) error {
+	bin, prefix := m.command()
+	args := append(append([]string{}, prefix...), "install", "--global")
+	for _, pkg := range pkgs {
+		args = append(args, pkg+"@latest")
+	}
+	res := m.host.runner().Run(ctx, bin, args...)
+	if res.Error != nil && res.ExitCode != 0 {
+		return commandError("npm install --global", res, npmHints)
+	}
+	return nil
+}
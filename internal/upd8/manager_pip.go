@@ -5,42 +5,166 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"regexp"
 	"sort"
+	"strings"
 )
 
 type pipManager struct {
-	runner CommandRunner
+	host   Host
 	binary string
+	// python, when set, runs checks as `python -m pip` against that
+	// interpreter instead of a pip binary on PATH.
+	python string
+	// name overrides the manager name for discovered environments.
+	name string
+	// user restricts the check to packages in the user site (~/.local).
+	user bool
+	// noUserSite leaves the user site out of the check, since a user manager
+	// reports it. It is kept when pip itself is installed there.
+	noUserSite bool
+	// site is the site-packages directory pip was found in by Detect.
+	site string
 }
 
 func (m *pipManager) Name() string {
+	if m.name != "" {
+		return m.name
+	}
 	if m.binary != "" {
 		return m.binary
 	}
 	return "pip"
 }
 
-func (m *pipManager) Detect(ctx context.Context) bool {
-	_ = ctx
-	bin := m.binary
-	if bin == "" {
-		bin = "pip"
+// command returns the executable and leading arguments that invoke pip.
+func (m *pipManager) command() (string, []string) {
+	if m.python != "" {
+		return m.python, []string{"-m", "pip"}
+	}
+	if m.binary != "" {
+		return m.binary, nil
+	}
+	return "pip", nil
+}
+
+func (m *pipManager) Detect(ctx context.Context) Detection {
+	if m.python != "" {
+		return m.detectInterpreter(ctx)
+	}
+
+	bin, _ := m.command()
+	det, output := probeBinary(ctx, m.host, bin)
+	if !det.Available {
+		return det
+	}
+
+	// pip and pip3 frequently share an interpreter; key them on the
+	// site-packages directory pip reports so Scanner can merge them.
+	probe := m.host.probe()
+	if site, python, ok := parsePipVersion(output); ok {
+		m.site = site
+		det.Identity = probe.EvalSymlinks(site)
+		det.Label = "python " + python
+	}
+	det.Interpreter = scriptInterpreter(probe, det.Path)
+	return det
+}
+
+// detectInterpreter checks that a discovered interpreter exists and has pip.
+func (m *pipManager) detectInterpreter(ctx context.Context) Detection {
+	probe := m.host.probe()
+	if !isFile(probe, m.python) {
+		return Detection{Reason: m.python + " not found"}
+	}
+
+	det := Detection{
+		Available:   true,
+		Path:        m.python,
+		Scope:       installScope(probe, m.python),
+		Identity:    probe.EvalSymlinks(m.python),
+		Interpreter: m.python,
 	}
-	return lookupBinary(bin)
+
+	res := m.host.runner().Run(ctx, m.python, "-m", "pip", "--version")
+	site, python, ok := parsePipVersion(res.CombineOutput())
+	if res.Error != nil || !ok {
+		return Detection{Reason: "pip is not installed for " + m.python}
+	}
+	det.Version = firstVersion(res.CombineOutput())
+	m.site = site
+	det.Identity = probe.EvalSymlinks(site)
+	det.Label = "python " + python
+	if m.user {
+		// The user site is a separate environment from the interpreter's own.
+		det.Identity = "user:" + det.Identity
+		det.Label += ", user"
+	}
+	return det
+}
+
+var pipVersionRegex = regexp.MustCompile(`^pip \S+ from (.+)[/\\]pip[/\\]? \(python ([^)]+)\)`)
+
+// parsePipVersion extracts the site-packages directory and Python version
+// from `pip --version`, e.g. "pip 24.0 from /usr/lib/python3/dist-packages/pip (python 3.12)".
+func parsePipVersion(output string) (site, python string, ok bool) {
+	line, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	m := pipVersionRegex.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// scriptInterpreter returns the Python interpreter named in a pip script's
+// shebang line. Shims written in shell (pyenv, asdf) yield "".
+func scriptInterpreter(p Prober, path string) string {
+	head, err := p.ReadFile(path, 256)
+	if err != nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(string(head), "\n")
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if !strings.HasPrefix(line, "#!") || len(fields) == 0 {
+		return ""
+	}
+
+	interp := fields[0]
+	if filepath.Base(interp) == "env" && len(fields) > 1 {
+		interp = fields[1]
+	}
+	if !strings.Contains(filepath.Base(interp), "python") {
+		return ""
+	}
+	return interp
+}
+
+var pipHints = Hints{
+	ErrNetwork:      "pip could not reach the index; check the network, PIP_INDEX_URL and proxy settings",
+	ErrPrerequisite: "pip is missing from this interpreter; install it with `python -m ensurepip --upgrade`",
+	ErrPermission:   "use a virtualenv or `--user` instead of the system site-packages",
 }
 
 func (m *pipManager) CheckUpdates(ctx context.Context) Result {
-	bin := m.binary
-	if bin == "" {
-		bin = "pip"
+	bin, prefix := m.command()
+	invocation := strings.Join(append([]string{bin}, prefix...), " ")
+
+	r := Result{Manager: m.Name(), UpdateCommand: fmt.Sprintf("%s install --upgrade -r requirements.txt", invocation)}
+	runner := m.host.runner()
+	if er, ok := runner.(EnvRunner); ok && m.noUserSite && !m.inUserSite() {
+		runner = er.WithEnv([]string{"PYTHONNOUSERSITE=1"})
 	}
 
-	r := Result{Manager: m.Name(), UpdateCommand: fmt.Sprintf("%s install --upgrade -r requirements.txt", bin)}
-	runner := safeRunner(m.runner)
+	args := append(append([]string{}, prefix...), "list", "--outdated", "--format=json")
+	if m.user {
+		args = append(args, "--user")
+	}
 
-	cmdRes := runner.Run(ctx, bin, "list", "--outdated", "--format=json")
+	cmdRes := runner.Run(ctx, bin, args...)
+	r.Usage = cmdRes.Usage
 	if cmdRes.Error != nil && cmdRes.ExitCode != 0 {
-		r.Err = fmt.Errorf("%s list failed: %w", bin, cmdRes.Error)
+		r.Err = commandError(invocation+" list --outdated", cmdRes, pipHints)
 		return r
 	}
 
@@ -57,7 +181,7 @@ func (m *pipManager) CheckUpdates(ctx context.Context) Result {
 
 	var entries []pipEntry
 	if err := json.Unmarshal(payload, &entries); err != nil {
-		r.Err = fmt.Errorf("parse %s outdated output: %w", bin, err)
+		r.Err = parseError(invocation+" list --outdated --format=json", err, pipHints)
 		return r
 	}
 
@@ -72,3 +196,25 @@ func (m *pipManager) CheckUpdates(ctx context.Context) Result {
 
 	return r
 }
+
+// inUserSite reports whether pip runs from the user site, where hiding the
+// user site would hide pip too.
+func (m *pipManager) inUserSite() bool {
+	home := m.host.probe().HomeDir()
+	return home != "" && strings.HasPrefix(m.site, filepath.Join(home, ".local")+string(filepath.Separator))
+}
+
+// Upgrade runs `pip install --upgrade` for pkgs in the same environment, and
+// in the user site for user installs.
+func (m *pipManager) Upgrade(ctx context.Context, pkgs []string) error {
+	bin, prefix := m.command()
+	args := append(append([]string{}, prefix...), "install", "--upgrade")
+	if m.user {
+		args = append(args, "--user")
+	}
+	res := m.host.runner().Run(ctx, bin, append(args, pkgs...)...)
+	if res.Error != nil && res.ExitCode != 0 {
+		return commandError("pip install --upgrade", res, pipHints)
+	}
+	return nil
+}
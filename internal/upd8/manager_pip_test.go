@@ -0,0 +1,58 @@
+package upd8
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPipUserSiteReportedOnce(t *testing.T) {
+	const version = "pip 24.0 from /usr/lib/python3/dist-packages/pip (python 3.12)\n"
+	system := `{"name": "requests", "version": "2.31.0", "latest_version": "2.32.3"}`
+	user := `{"name": "black", "version": "24.1.0", "latest_version": "24.10.0"}`
+	machine := envRunner{run: func(env []string, argv string) CommandResult {
+		switch {
+		case strings.HasSuffix(argv, " --version"):
+			return CommandResult{Stdout: []byte(version)}
+		case strings.HasSuffix(argv, " --user"):
+			return CommandResult{Stdout: []byte("[" + user + "]")}
+		case strings.Contains(argv, " list --outdated"):
+			// Like python itself, list the user site unless told not to.
+			if slices.Contains(env, "PYTHONNOUSERSITE=1") {
+				return CommandResult{Stdout: []byte("[" + system + "]")}
+			}
+			return CommandResult{Stdout: []byte("[" + system + ", " + user + "]")}
+		}
+		return CommandResult{ExitCode: 1, Stderr: []byte("unexpected " + argv)}
+	}}
+	host := Host{Runner: machine, Probe: fakeProber{
+		paths: map[string]string{"pip3": "/usr/bin/pip3", "python3": "/usr/bin/python3"},
+		files: map[string]FileStat{
+			"/usr/bin/python3":      {},
+			"/home/user/.local/lib": {IsDir: true},
+		},
+	}}
+
+	var pip []Manager
+	for _, m := range DefaultManagers(host, Config{}) {
+		if strings.HasPrefix(m.Name(), "pip") {
+			pip = append(pip, m)
+		}
+	}
+	found := map[string][]string{}
+	for _, r := range (Scanner{Managers: pip}).Scan(context.Background()) {
+		if r.Err != nil {
+			t.Errorf("%s: %v", r.Manager, r.Err)
+		}
+		for _, item := range r.Items {
+			found[item.Name] = append(found[item.Name], r.Manager)
+		}
+	}
+	if got := found["black"]; !slices.Equal(got, []string{"pip:user"}) {
+		t.Errorf("black reported by %v, want only pip:user", got)
+	}
+	if got := found["requests"]; len(got) != 1 {
+		t.Errorf("requests reported by %v, want one manager", got)
+	}
+}
@@ -3,29 +3,34 @@ package upd8
 import (
 	"bytes"
 	"context"
-	"fmt"
 	"sort"
 	"strings"
 )
 
 type snapManager struct {
-	runner CommandRunner
+	host Host
 }
 
 func (m *snapManager) Name() string { return "snap" }
 
-func (m *snapManager) Detect(ctx context.Context) bool {
-	_ = ctx
-	return lookupBinary("snap")
+func (m *snapManager) Detect(ctx context.Context) Detection {
+	return detectBinary(ctx, m.host, "snap")
+}
+
+var snapHints = Hints{
+	ErrPermission:   "run `sudo snap refresh --list`",
+	ErrPrerequisite: "snapd is not reachable; start it with `sudo systemctl start snapd.socket`",
+	ErrNetwork:      "snapd could not reach the store; check the network or `snap get system proxy`",
 }
 
 func (m *snapManager) CheckUpdates(ctx context.Context) Result {
 	r := Result{Manager: m.Name(), UpdateCommand: "snap refresh"}
-	runner := safeRunner(m.runner)
+	runner := m.host.runner()
 
 	cmdRes := runner.Run(ctx, "snap", "refresh", "--list")
+	r.Usage = cmdRes.Usage
 	if cmdRes.Error != nil && cmdRes.ExitCode != 0 {
-		r.Err = fmt.Errorf("snap refresh --list failed: %w", cmdRes.Error)
+		r.Err = commandError("snap refresh --list", cmdRes, snapHints)
 		return r
 	}
 
@@ -62,3 +67,12 @@ func (m *snapManager) CheckUpdates(ctx context.Context) Result {
 	sort.Slice(r.Items, func(i, j int) bool { return r.Items[i].Name < r.Items[j].Name })
 	return r
 }
+
+// Upgrade runs `snap refresh` for pkgs.
+func (m *snapManager) Upgrade(ctx context.Context, pkgs []string) error {
+	res := m.host.runner().Run(ctx, "snap", append([]string{"refresh"}, pkgs...)...)
+	if res.Error != nil && res.ExitCode != 0 {
+		return commandError("snap refresh", res, snapHints)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package upd8
+
+import (
+	"path/filepath"
+)
+
+// nodeEnv is a Node.js installation managed by a version manager.
+type nodeEnv struct {
+	// name becomes the manager name, e.g. "npm:nvm-20.11.1".
+	name string
+	node string
+}
+
+// discoverNodeEnvs finds Node.js versions installed by nvm, fnm, volta,
+// asdf and mise. Binaries that resolve to the same file are returned once.
+func discoverNodeEnvs(p Prober, cfg NodeConfig) []nodeEnv {
+	if cfg.DisableDiscovery {
+		return nil
+	}
+
+	home := p.HomeDir()
+	dataHome := p.Getenv("XDG_DATA_HOME")
+	if dataHome == "" && home != "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+
+	root := func(envVar string, fallbacks ...string) []string {
+		if dir := p.Getenv(envVar); dir != "" {
+			return []string{dir}
+		}
+		var dirs []string
+		for _, fb := range fallbacks {
+			if fb != "" {
+				dirs = append(dirs, fb)
+			}
+		}
+		return dirs
+	}
+	under := func(base string, parts ...string) string {
+		if base == "" {
+			return ""
+		}
+		return filepath.Join(append([]string{base}, parts...)...)
+	}
+
+	fnmRoots := []string{under(dataHome, "fnm"), under(home, ".fnm")}
+	if p.OS() == "darwin" {
+		fnmRoots = append(fnmRoots, under(home, "Library", "Application Support", "fnm"))
+	}
+
+	sources := []struct {
+		tool    string
+		roots   []string
+		pattern []string
+	}{
+		{"nvm", root("NVM_DIR", under(home, ".nvm")), []string{"versions", "node", "*", "bin", "node"}},
+		{"fnm", root("FNM_DIR", fnmRoots...), []string{"node-versions", "*", "installation", "bin", "node"}},
+		{"volta", root("VOLTA_HOME", under(home, ".volta")), []string{"tools", "image", "node", "*", "bin", "node"}},
+		{"asdf", root("ASDF_DATA_DIR", under(home, ".asdf")), []string{"installs", "nodejs", "*", "bin", "node"}},
+		{"mise", root("MISE_DATA_DIR", under(dataHome, "mise")), []string{"installs", "node", "*", "bin", "node"}},
+	}
+
+	var envs []nodeEnv
+	seen := make(map[string]bool)
+	for _, src := range sources {
+		for _, dir := range src.roots {
+			pattern := filepath.Join(append([]string{dir}, src.pattern...)...)
+			for _, node := range p.Glob(pattern) {
+				key := p.EvalSymlinks(node)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+
+				// The version directory sits a fixed number of levels above bin/node.
+				versionDir := filepath.Dir(filepath.Dir(node))
+				if src.tool == "fnm" {
+					versionDir = filepath.Dir(versionDir)
+				}
+				version := firstVersion(filepath.Base(versionDir))
+				if version == "" {
+					version = filepath.Base(versionDir)
+				}
+				envs = append(envs, nodeEnv{name: "npm:" + src.tool + "-" + version, node: node})
+			}
+		}
+	}
+	return envs
+}
+
+// npmCLI returns the npm entry point bundled with a node binary.
+func npmCLI(p Prober, node string) string {
+	return filepath.Join(nodePrefix(p, node), "lib", "node_modules", "npm", "bin", "npm-cli.js")
+}
+
+// nodePrefix returns the install prefix of a node binary; npm uses it as the
+// default global prefix.
+func nodePrefix(p Prober, node string) string {
+	return filepath.Dir(filepath.Dir(p.EvalSymlinks(node)))
+}
@@ -0,0 +1,33 @@
+package upd8
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ConfigDir returns the directory holding upd8's configuration file.
+func ConfigDir() string {
+	return xdgDir("XDG_CONFIG_HOME", ".config")
+}
+
+// StateDir returns the directory where upd8 keeps persistent state such as scan history.
+func StateDir() string {
+	return xdgDir("XDG_STATE_HOME", filepath.Join(".local", "state"))
+}
+
+// CacheDir returns the directory for disposable data such as cached results.
+func CacheDir() string {
+	return xdgDir("XDG_CACHE_HOME", ".cache")
+}
+
+// xdgDir resolves an XDG base directory, falling back to a path under $HOME.
+func xdgDir(envVar, fallback string) string {
+	if dir := os.Getenv(envVar); dir != "" && filepath.IsAbs(dir) {
+		return filepath.Join(dir, "upd8")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return filepath.Join(os.TempDir(), "upd8")
+	}
+	return filepath.Join(home, fallback, "upd8")
+}
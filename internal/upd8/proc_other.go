@@ -0,0 +1,16 @@
+//go:build !unix
+
+package upd8
+
+import (
+	"os"
+	"os/exec"
+	"time"
+)
+
+// startGroup only kills the direct child where process groups are not
+// available; exec.Cmd.WaitDelay still stops upd8 from hanging on its pipes.
+func startGroup(cmd *exec.Cmd, grace time.Duration) {}
+
+// maxRSS is not reported on this platform.
+func maxRSS(state *os.ProcessState) int64 { return 0 }
@@ -0,0 +1,39 @@
+//go:build unix
+
+package upd8
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// startGroup makes cmd the leader of a new process group. When ctx is done the
+// whole group gets SIGTERM, and SIGKILL if anything is still alive after
+// grace, so helpers such as brew's Ruby children cannot outlive a timeout.
+func startGroup(cmd *exec.Cmd, grace time.Duration) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		pgid := -cmd.Process.Pid
+		if err := syscall.Kill(pgid, syscall.SIGTERM); err != nil {
+			return cmd.Process.Kill()
+		}
+		time.AfterFunc(grace, func() { syscall.Kill(pgid, syscall.SIGKILL) })
+		return nil
+	}
+}
+
+// maxRSS returns the peak resident set size of a finished process in bytes.
+func maxRSS(state *os.ProcessState) int64 {
+	usage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	// Linux and the BSDs report kilobytes, macOS reports bytes.
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		return int64(usage.Maxrss)
+	}
+	return int64(usage.Maxrss) * 1024
+}
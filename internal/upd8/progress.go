@@ -0,0 +1,199 @@
+package upd8
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProgressReporter is notified as a Scanner works through its managers,
+// which are named by result key (see Result.Key). Calls may arrive
+// concurrently from several goroutines.
+type ProgressReporter interface {
+	Queued(manager string)
+	Started(manager string)
+	Finished(res Result)
+	Done()
+}
+
+type noProgress struct{}
+
+func (noProgress) Queued(string)   {}
+func (noProgress) Started(string)  {}
+func (noProgress) Finished(Result) {}
+func (noProgress) Done()           {}
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// ProgressDisplay draws a live per-manager status block, redrawing it in place
+// until the scan is done and then erasing it so only the final report remains.
+// It is meant for a terminal; see IsTerminal.
+type ProgressDisplay struct {
+	Writer      io.Writer
+	EnableColor bool
+	Interval    time.Duration
+
+	mu      sync.Mutex
+	rows    []*progressRow
+	drawn   int
+	frame   int
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+type progressRow struct {
+	manager  string
+	started  time.Time
+	finished time.Time
+	result   *Result
+}
+
+// NewProgressDisplay returns a display that redraws w every 100ms.
+func NewProgressDisplay(w io.Writer, enableColor bool) *ProgressDisplay {
+	return &ProgressDisplay{Writer: w, EnableColor: enableColor, Interval: 100 * time.Millisecond}
+}
+
+func (p *ProgressDisplay) Queued(manager string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.rows = append(p.rows, &progressRow{manager: manager})
+	if p.stop == nil {
+		p.stop = make(chan struct{})
+		p.stopped = make(chan struct{})
+		go p.loop(p.stop, p.stopped)
+	}
+}
+
+func (p *ProgressDisplay) Started(manager string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if row := p.find(manager); row != nil {
+		row.started = time.Now()
+	}
+}
+
+func (p *ProgressDisplay) Finished(res Result) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if row := p.find(res.Key()); row != nil {
+		row.finished = time.Now()
+		row.result = &res
+	}
+}
+
+// Done stops redrawing and clears the block. The display can be reused for
+// the next scan afterwards.
+func (p *ProgressDisplay) Done() {
+	p.mu.Lock()
+	stop, stopped := p.stop, p.stopped
+	p.stop, p.stopped = nil, nil
+	p.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-stopped
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.erase()
+	p.rows = nil
+}
+
+func (p *ProgressDisplay) loop(stop, stopped chan struct{}) {
+	defer close(stopped)
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		p.mu.Lock()
+		p.draw()
+		p.mu.Unlock()
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *ProgressDisplay) find(manager string) *progressRow {
+	for _, row := range p.rows {
+		if row.manager == manager && row.result == nil {
+			return row
+		}
+	}
+	return nil
+}
+
+// draw moves the cursor back over the previous frame and rewrites every line.
+func (p *ProgressDisplay) draw() {
+	var b strings.Builder
+	if p.drawn > 0 {
+		fmt.Fprintf(&b, "\033[%dA", p.drawn)
+	}
+
+	now := time.Now()
+	spinner := spinnerFrames[p.frame%len(spinnerFrames)]
+	p.frame++
+
+	for _, row := range p.rows {
+		b.WriteString("\r\033[2K")
+		b.WriteString(p.line(row, spinner, now))
+		b.WriteString("\n")
+	}
+
+	p.drawn = len(p.rows)
+	io.WriteString(p.Writer, b.String())
+}
+
+func (p *ProgressDisplay) erase() {
+	if p.drawn == 0 {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\033[%dA", p.drawn)
+	for i := 0; i < p.drawn; i++ {
+		b.WriteString("\r\033[2K\n")
+	}
+	fmt.Fprintf(&b, "\033[%dA", p.drawn)
+	io.WriteString(p.Writer, b.String())
+	p.drawn = 0
+}
+
+func (p *ProgressDisplay) line(row *progressRow, spinner string, now time.Time) string {
+	switch {
+	case row.result != nil:
+		elapsed := formatElapsed(row.finished.Sub(row.started))
+		if row.result.Skipped != "" {
+			return fmt.Sprintf("- checking %s… skipped (%s)", row.manager, row.result.Skipped)
+		}
+		if row.result.Err != nil {
+			return colorize(p.EnableColor, "✗", ansiRed) + fmt.Sprintf(" checking %s… %s %s", row.manager, ErrorKind(row.result.Err), elapsed)
+		}
+		return colorize(p.EnableColor, "✓", ansiGreen) + fmt.Sprintf(" checking %s… done %s (%d outdated)", row.manager, elapsed, len(row.result.Items))
+	case !row.started.IsZero():
+		return colorize(p.EnableColor, spinner, ansiCyan) + fmt.Sprintf(" checking %s… %s", row.manager, formatElapsed(now.Sub(row.started)))
+	default:
+		return "  " + row.manager + " waiting"
+	}
+}
+
+func formatElapsed(d time.Duration) string {
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
+
+// IsTerminal reports whether f is attached to a character device such as a TTY.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,112 @@
+package upd8
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// pythonEnv is a Python installation found outside of PATH.
+type pythonEnv struct {
+	// name becomes the manager name, e.g. "pip:pyenv-3.11.4".
+	name   string
+	python string
+	user   bool
+}
+
+// discoverPythonEnvs finds the Python interpreters a developer is likely to
+// install tools into: pyenv versions, conda environments, uv-managed Pythons,
+// the user site of the system python3 and any configured virtualenvs.
+// Interpreters that resolve to the same file are only returned once.
+func discoverPythonEnvs(p Prober, cfg PythonConfig) []pythonEnv {
+	var envs []pythonEnv
+
+	home := p.HomeDir()
+
+	if !cfg.DisableDiscovery {
+		pyenvRoot := p.Getenv("PYENV_ROOT")
+		if pyenvRoot == "" && home != "" {
+			pyenvRoot = filepath.Join(home, ".pyenv")
+		}
+		if pyenvRoot != "" {
+			for _, python := range p.Glob(filepath.Join(pyenvRoot, "versions", "*", "bin", "python")) {
+				version := filepath.Base(filepath.Dir(filepath.Dir(python)))
+				envs = append(envs, pythonEnv{name: "pip:pyenv-" + version, python: python})
+			}
+		}
+
+		var condaRoots []string
+		if prefix := p.Getenv("CONDA_PREFIX"); prefix != "" {
+			condaRoots = append(condaRoots, prefix)
+		}
+		if home != "" {
+			for _, dir := range []string{"miniconda3", "anaconda3", "miniforge3", "mambaforge"} {
+				condaRoots = append(condaRoots, filepath.Join(home, dir))
+			}
+		}
+		condaRoots = append(condaRoots, "/opt/conda")
+		for _, root := range condaRoots {
+			if python := filepath.Join(root, "bin", "python"); isFile(p, python) {
+				envs = append(envs, pythonEnv{name: "pip:conda-" + filepath.Base(root), python: python})
+			}
+			for _, python := range p.Glob(filepath.Join(root, "envs", "*", "bin", "python")) {
+				env := filepath.Base(filepath.Dir(filepath.Dir(python)))
+				envs = append(envs, pythonEnv{name: "pip:conda-" + env, python: python})
+			}
+		}
+
+		uvDir := p.Getenv("UV_PYTHON_INSTALL_DIR")
+		if uvDir == "" && home != "" {
+			uvDir = filepath.Join(home, ".local", "share", "uv", "python")
+		}
+		if uvDir != "" {
+			for _, python := range p.Glob(filepath.Join(uvDir, "*", "bin", "python3")) {
+				dist := filepath.Base(filepath.Dir(filepath.Dir(python)))
+				label := firstVersion(dist)
+				if label == "" {
+					label = dist
+				}
+				envs = append(envs, pythonEnv{name: "pip:uv-" + label, python: python})
+			}
+		}
+
+		if home != "" {
+			if python, err := p.LookPath("python3"); err == nil && dirExists(p, filepath.Join(home, ".local", "lib")) {
+				envs = append(envs, pythonEnv{name: "pip:user", python: python, user: true})
+			}
+		}
+	}
+
+	for _, venv := range cfg.Virtualenvs {
+		dir := expandHome(venv, home)
+		python := filepath.Join(dir, "bin", "python")
+		envs = append(envs, pythonEnv{name: "pip:venv-" + filepath.Base(dir), python: python})
+	}
+
+	return uniquePythonEnvs(p, envs)
+}
+
+func uniquePythonEnvs(p Prober, envs []pythonEnv) []pythonEnv {
+	seen := make(map[string]bool, len(envs))
+	names := make(map[string]int, len(envs))
+	out := envs[:0]
+	for _, env := range envs {
+		key := p.EvalSymlinks(env.python)
+		if env.user {
+			key = "user:" + key
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		// Keep manager names unique, e.g. two venvs both called ".venv".
+		if n := names[env.name]; n > 0 {
+			names[env.name]++
+			env.name = fmt.Sprintf("%s-%d", env.name, n+1)
+		} else {
+			names[env.name] = 1
+		}
+		out = append(out, env)
+	}
+	return out
+}
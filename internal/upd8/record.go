@@ -0,0 +1,452 @@
+package upd8
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recordingVersion is bumped when the bundle format changes incompatibly.
+const recordingVersion = 1
+
+// Recording is a bundle of everything a scan learned from the machines it
+// inspected: each command with its output, each filesystem and environment
+// lookup, and the settings that decided which managers ran. Replaying it
+// reproduces the scan without running anything.
+type Recording struct {
+	Version  int               `json:"version"`
+	Time     time.Time         `json:"time"`
+	OS       string            `json:"os"`
+	Config   Config            `json:"config"`
+	Offline  bool              `json:"offline,omitempty"`
+	Refresh  bool              `json:"refresh,omitempty"`
+	Commands []RecordedCommand `json:"commands"`
+	Probes   []RecordedProbe   `json:"probes"`
+	Connects []RecordedConnect `json:"connects,omitempty"`
+}
+
+// RecordedCommand is one command run by a manager.
+type RecordedCommand struct {
+	Host string   `json:"host,omitempty"`
+	Argv []string `json:"argv"`
+	// Env names the extra variables set for the manager (see ScanConfig.Env).
+	// Their values are left out, since they often hold tokens or credentials,
+	// and the fixed commandEnv policy is not repeated.
+	Env        []string `json:"env,omitempty"`
+	Stdout     string   `json:"stdout"`
+	Stderr     string   `json:"stderr"`
+	ExitCode   int      `json:"exit_code"`
+	Error      string   `json:"error,omitempty"`
+	ErrorType  string   `json:"error_type,omitempty"`
+	Truncated  bool     `json:"truncated,omitempty"`
+	DurationMs int64    `json:"duration_ms"`
+}
+
+// RecordedProbe is one Prober lookup and its answer.
+type RecordedProbe struct {
+	Host   string    `json:"host,omitempty"`
+	Op     string    `json:"op"`
+	Args   []string  `json:"args,omitempty"`
+	Value  string    `json:"value,omitempty"`
+	Values []string  `json:"values,omitempty"`
+	OK     bool      `json:"ok,omitempty"`
+	Stat   *FileStat `json:"stat,omitempty"`
+}
+
+// RecordedConnect is the outcome of a remote target's connection check.
+type RecordedConnect struct {
+	Host      string `json:"host"`
+	Error     string `json:"error,omitempty"`
+	ErrorKind string `json:"error_kind,omitempty"`
+	ErrorHint string `json:"error_hint,omitempty"`
+	Stderr    string `json:"stderr,omitempty"`
+}
+
+// LoadRecording reads a bundle written by Recorder.Save.
+func LoadRecording(path string) (Recording, error) {
+	var rec Recording
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return rec, fmt.Errorf("read recording: %w", err)
+	}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return rec, fmt.Errorf("parse recording %s: %w", path, err)
+	}
+	if rec.Version != recordingVersion {
+		return rec, fmt.Errorf("recording %s has format version %d, want %d", path, rec.Version, recordingVersion)
+	}
+	return rec, nil
+}
+
+// Recorder captures commands and lookups while a scan runs. Wrap each
+// host's runner, prober and connection check before handing them out.
+type Recorder struct {
+	mu  sync.Mutex
+	rec Recording
+}
+
+// NewRecorder starts a recording.
+func NewRecorder() *Recorder {
+	return &Recorder{rec: Recording{Version: recordingVersion, Time: time.Now().UTC(), OS: runtime.GOOS}}
+}
+
+// Host returns host with its runner and prober recording into r.
+func (r *Recorder) Host(host Host) Host {
+	host.Runner = recordingRunner{rec: r, host: host.Name, inner: host.runner()}
+	host.Probe = recordingProber{rec: r, host: host.Name, inner: host.probe()}
+	return host
+}
+
+// Connect returns connect with its outcome recorded under host.
+func (r *Recorder) Connect(host string, connect func(context.Context) error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		err := connect(ctx)
+		c := RecordedConnect{Host: host}
+		if err != nil {
+			c.Error, c.ErrorKind, c.ErrorHint, c.Stderr = err.Error(), ErrorKind(err), ErrorHint(err), ErrorStderr(err)
+		}
+		r.mu.Lock()
+		r.rec.Connects = append(r.rec.Connects, c)
+		r.mu.Unlock()
+		return err
+	}
+}
+
+// redactedValue replaces the values of scan.env in a saved bundle.
+const redactedValue = "REDACTED"
+
+// Save writes the bundle with the settings the scan ran with. Bundles are
+// meant to be attached to bug reports, so scan.env values are redacted.
+func (r *Recorder) Save(path string, cfg Config, offline, refresh bool) error {
+	r.mu.Lock()
+	rec := r.rec
+	r.mu.Unlock()
+	rec.Config, rec.Offline, rec.Refresh = redactConfig(cfg), offline, refresh
+
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode recording: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write recording: %w", err)
+	}
+	return nil
+}
+
+// redactConfig returns cfg with the values of scan.env replaced. Replays do
+// not need them: commands are recorded as managers issued them, before
+// their environment was added, and matched by argv alone.
+func redactConfig(cfg Config) Config {
+	if len(cfg.Scan.Env) == 0 {
+		return cfg
+	}
+	env := make(map[string]map[string]string, len(cfg.Scan.Env))
+	for manager, vars := range cfg.Scan.Env {
+		env[manager] = make(map[string]string, len(vars))
+		for name := range vars {
+			env[manager][name] = redactedValue
+		}
+	}
+	cfg.Scan.Env = env
+	return cfg
+}
+
+func (r *Recorder) command(c RecordedCommand) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rec.Commands = append(r.rec.Commands, c)
+}
+
+func (r *Recorder) probe(p RecordedProbe) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rec.Probes = append(r.rec.Probes, p)
+}
+
+type recordingRunner struct {
+	rec   *Recorder
+	host  string
+	env   []string
+	inner CommandRunner
+}
+
+func (r recordingRunner) Run(ctx context.Context, cmd string, args ...string) CommandResult {
+	res := r.inner.Run(ctx, cmd, args...)
+	c := RecordedCommand{
+		Host:       r.host,
+		Argv:       append([]string{cmd}, args...),
+		Env:        r.env,
+		Stdout:     string(res.Stdout),
+		Stderr:     string(res.Stderr),
+		ExitCode:   res.ExitCode,
+		Truncated:  res.Truncated,
+		DurationMs: res.Usage.Wall.Milliseconds(),
+	}
+	if res.Error != nil {
+		c.Error = res.Error.Error()
+		switch {
+		case errors.Is(res.Error, ErrTimeout):
+			c.ErrorType = "timeout"
+		case errors.Is(res.Error, exec.ErrNotFound):
+			c.ErrorType = "not_found"
+		}
+	}
+	r.rec.command(c)
+	return res
+}
+
+func (r recordingRunner) innerRunner() CommandRunner { return r.inner }
+
+func (r recordingRunner) around(inner CommandRunner) CommandRunner {
+	r.inner = inner
+	return r
+}
+
+func (r recordingRunner) WithEnv(env []string) CommandRunner {
+	if er, ok := r.inner.(EnvRunner); ok {
+		r.inner = er.WithEnv(env)
+		names := append([]string(nil), r.env...)
+		for _, kv := range env {
+			name, _, _ := strings.Cut(kv, "=")
+			names = append(names, name)
+		}
+		r.env = names
+	}
+	return r
+}
+
+type recordingProber struct {
+	rec   *Recorder
+	host  string
+	inner Prober
+}
+
+func (p recordingProber) save(rp RecordedProbe) {
+	rp.Host = p.host
+	p.rec.probe(rp)
+}
+
+func (p recordingProber) LookPath(name string) (string, error) {
+	path, err := p.inner.LookPath(name)
+	p.save(RecordedProbe{Op: "lookpath", Args: []string{name}, Value: path, OK: err == nil})
+	return path, err
+}
+
+func (p recordingProber) Getenv(key string) string {
+	v := p.inner.Getenv(key)
+	p.save(RecordedProbe{Op: "getenv", Args: []string{key}, Value: v})
+	return v
+}
+
+func (p recordingProber) HomeDir() string {
+	v := p.inner.HomeDir()
+	p.save(RecordedProbe{Op: "home", Value: v})
+	return v
+}
+
+func (p recordingProber) OS() string {
+	v := p.inner.OS()
+	p.save(RecordedProbe{Op: "os", Value: v})
+	return v
+}
+
+func (p recordingProber) IsRoot() bool {
+	v := p.inner.IsRoot()
+	p.save(RecordedProbe{Op: "root", OK: v})
+	return v
+}
+
+func (p recordingProber) Glob(pattern string) []string {
+	v := p.inner.Glob(pattern)
+	p.save(RecordedProbe{Op: "glob", Args: []string{pattern}, Values: v})
+	return v
+}
+
+func (p recordingProber) Stat(path string) (FileStat, bool) {
+	st, ok := p.inner.Stat(path)
+	rp := RecordedProbe{Op: "stat", Args: []string{path}, OK: ok}
+	if ok {
+		rp.Stat = &st
+	}
+	p.save(rp)
+	return st, ok
+}
+
+func (p recordingProber) EvalSymlinks(path string) string {
+	v := p.inner.EvalSymlinks(path)
+	p.save(RecordedProbe{Op: "realpath", Args: []string{path}, Value: v})
+	return v
+}
+
+func (p recordingProber) ReadFile(path string, limit int) ([]byte, error) {
+	data, err := p.inner.ReadFile(path, limit)
+	p.save(RecordedProbe{Op: "read", Args: []string{path, fmt.Sprint(limit)}, Value: string(data), OK: err == nil})
+	return data, err
+}
+
+// Replayer serves a Recording back in place of the real machines.
+type Replayer struct {
+	rec Recording
+
+	mu     sync.Mutex
+	used   []bool
+	probes map[string]RecordedProbe
+}
+
+// NewReplayer returns a replayer for rec.
+func NewReplayer(rec Recording) *Replayer {
+	r := &Replayer{rec: rec, used: make([]bool, len(rec.Commands)), probes: map[string]RecordedProbe{}}
+	for _, p := range rec.Probes {
+		r.probes[probeKey(p.Host, p.Op, p.Args)] = p
+	}
+	return r
+}
+
+// Host returns host with its runner and prober answering from the recording.
+func (r *Replayer) Host(host Host) Host {
+	host.Runner = replayRunner{rep: r, host: host.Name}
+	host.Probe = replayProber{rep: r, host: host.Name}
+	return host
+}
+
+// Connect returns the recorded outcome of host's connection check.
+func (r *Replayer) Connect(host string) func(context.Context) error {
+	return func(context.Context) error {
+		for _, c := range r.rec.Connects {
+			if c.Host != host {
+				continue
+			}
+			return restoreError(c.Error, c.ErrorKind, c.Stderr, c.ErrorHint)
+		}
+		return nil
+	}
+}
+
+// next hands out recorded runs of the same command in the order they were
+// recorded, so retries replay each attempt.
+func (r *Replayer) next(host string, argv []string) (RecordedCommand, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	want := strings.Join(argv, "\x00")
+	for i, c := range r.rec.Commands {
+		if r.used[i] || c.Host != host || strings.Join(c.Argv, "\x00") != want {
+			continue
+		}
+		r.used[i] = true
+		return c, true
+	}
+	return RecordedCommand{}, false
+}
+
+func probeKey(host, op string, args []string) string {
+	return strings.Join(append([]string{host, op}, args...), "\x00")
+}
+
+// replayError restores a recorded command error with its type, so it is
+// classified the same way as the original.
+type replayError struct {
+	msg  string
+	kind error
+}
+
+func (e replayError) Error() string { return e.msg }
+func (e replayError) Unwrap() error { return e.kind }
+
+type replayRunner struct {
+	rep  *Replayer
+	host string
+}
+
+func (r replayRunner) Run(_ context.Context, cmd string, args ...string) CommandResult {
+	argv := append([]string{cmd}, args...)
+	c, ok := r.rep.next(r.host, argv)
+	if !ok {
+		return CommandResult{
+			ExitCode: -1,
+			Error:    replayError{msg: "not in recording: " + strings.Join(argv, " "), kind: exec.ErrNotFound},
+		}
+	}
+
+	res := CommandResult{
+		Stdout:    []byte(c.Stdout),
+		Stderr:    []byte(c.Stderr),
+		ExitCode:  c.ExitCode,
+		Truncated: c.Truncated,
+		Usage:     Usage{Wall: time.Duration(c.DurationMs) * time.Millisecond},
+	}
+	if c.Error != "" {
+		err := replayError{msg: c.Error}
+		switch c.ErrorType {
+		case "timeout":
+			err.kind = ErrTimeout
+		case "not_found":
+			err.kind = exec.ErrNotFound
+		}
+		res.Error = err
+	}
+	return res
+}
+
+// WithEnv is accepted so managers are built the same way as when recording;
+// the recorded output already reflects the environment.
+func (r replayRunner) WithEnv([]string) CommandRunner { return r }
+
+// Elevation was recorded along with the output, so nothing runs below a
+// replay.
+func (r replayRunner) innerRunner() CommandRunner         { return nil }
+func (r replayRunner) around(CommandRunner) CommandRunner { return r }
+
+type replayProber struct {
+	rep  *Replayer
+	host string
+}
+
+func (p replayProber) get(op string, args ...string) RecordedProbe {
+	return p.rep.probes[probeKey(p.host, op, args)]
+}
+
+func (p replayProber) LookPath(name string) (string, error) {
+	rp := p.get("lookpath", name)
+	if !rp.OK {
+		return "", fmt.Errorf("%s: %w", name, exec.ErrNotFound)
+	}
+	return rp.Value, nil
+}
+
+func (p replayProber) Getenv(key string) string { return p.get("getenv", key).Value }
+func (p replayProber) HomeDir() string          { return p.get("home").Value }
+func (p replayProber) OS() string               { return p.get("os").Value }
+func (p replayProber) IsRoot() bool             { return p.get("root").OK }
+func (p replayProber) Glob(pattern string) []string {
+	return p.get("glob", pattern).Values
+}
+
+func (p replayProber) Stat(path string) (FileStat, bool) {
+	rp := p.get("stat", path)
+	if !rp.OK || rp.Stat == nil {
+		return FileStat{}, false
+	}
+	return *rp.Stat, true
+}
+
+func (p replayProber) EvalSymlinks(path string) string {
+	if rp := p.get("realpath", path); rp.Value != "" {
+		return rp.Value
+	}
+	return path
+}
+
+func (p replayProber) ReadFile(path string, limit int) ([]byte, error) {
+	rp := p.get("read", path, fmt.Sprint(limit))
+	if !rp.OK {
+		return nil, errors.New("cannot read " + path)
+	}
+	return []byte(rp.Value), nil
+}
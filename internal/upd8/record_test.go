@@ -0,0 +1,76 @@
+package upd8
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplaySudoEnv(t *testing.T) {
+	cfg := Config{Scan: ScanConfig{
+		RequiresRoot: map[string]bool{"snap": true},
+		Env:          map[string]map[string]string{"snap": {"TOKEN": "s3cret"}},
+	}}
+	// Only sudo with the token set answers; snap run any other way fails.
+	machine := runnerFunc(func(cmd string, args []string) CommandResult {
+		argv := strings.Join(append([]string{cmd}, args...), " ")
+		if cmd != "sudo" || !strings.Contains(argv, " TOKEN=s3cret ") {
+			return CommandResult{ExitCode: 1, Stderr: []byte("permission denied: " + argv), Error: os.ErrPermission}
+		}
+		switch {
+		case strings.HasSuffix(argv, " --version"):
+			return CommandResult{Stdout: []byte("snap 2.63\n")}
+		case strings.HasSuffix(argv, " refresh --list"):
+			return CommandResult{Stdout: []byte("Name  Version  Rev\nfirefox  128.0  4650\n")}
+		}
+		return CommandResult{ExitCode: 1, Error: os.ErrNotExist}
+	})
+	scan := func(host Host, cfg Config) []Result {
+		var snap []Manager
+		for _, m := range DefaultManagers(host, cfg) {
+			if m.Name() == "snap" {
+				snap = append(snap, m)
+			}
+		}
+		results := Scanner{Managers: snap}.Scan(context.Background())
+		for i := range results {
+			results[i].Usage = Usage{}
+		}
+		return results
+	}
+	probe := fakeProber{paths: map[string]string{"snap": "/usr/bin/snap", "sudo": "/usr/bin/sudo"}}
+
+	recorder := NewRecorder()
+	host := recorder.Host(Host{Runner: machine, Probe: probe})
+	host.Sudo = "sudo"
+	recorded := scan(host, cfg)
+	if len(recorded) != 1 || recorded[0].Err != nil || len(recorded[0].Items) != 1 {
+		t.Fatalf("recorded scan = %+v, want firefox outdated", recorded)
+	}
+
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	if err := recorder.Save(path, cfg, false, false); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Errorf("bundle contains the scan.env value:\n%s", data)
+	}
+
+	rec, err := LoadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayer := NewReplayer(rec)
+	host = replayer.Host(Host{})
+	host.Sudo = "sudo"
+	if replayed := scan(host, rec.Config); !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("replayed scan = %+v, want %+v", replayed, recorded)
+	}
+}
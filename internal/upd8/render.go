@@ -27,6 +27,10 @@ type Renderer struct {
 	ShowPackages bool
 	Timestamp    bool
 	EmptyMessage string
+	// Now is the reference time for outdated ages; zero means time.Now().
+	Now time.Time
+	// ShowDetection appends where each manager was found and its version.
+	ShowDetection bool
 }
 
 func (r Renderer) Render(results []Result) {
@@ -47,7 +51,22 @@ func (r Renderer) Render(results []Result) {
 		fmt.Fprintf(r.Writer, "\n[%s]\n", time.Now().Format(time.RFC3339))
 	}
 
+	now := r.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	showAge := false
+	for _, res := range results {
+		if res.OldestOutdated(now) > 0 {
+			showAge = true
+			break
+		}
+	}
+
 	headers := []string{"Manager", "Outdated", "Packages", "Update Command"}
+	if showAge {
+		headers = []string{"Manager", "Outdated", "Oldest", "Packages", "Update Command"}
+	}
 	rows := make([][]string, 0, len(results)+1)
 	rows = append(rows, headers)
 
@@ -55,12 +74,34 @@ func (r Renderer) Render(results []Result) {
 
 	for _, res := range results {
 		if res.Err != nil {
-			rows = append(rows, []string{
-				colorize(r.EnableColor, res.Manager, ansiHiYellow),
-				colorize(r.EnableColor, "error", ansiRed),
+			name := colorize(r.EnableColor, res.DisplayName(), ansiHiYellow)
+			if res.Attempts > 1 {
+				name += fmt.Sprintf(" (%d attempts)", res.Attempts)
+			}
+			row := []string{
+				name,
+				colorize(r.EnableColor, ErrorKind(res.Err), ansiRed),
 				truncate(res.Err.Error(), 60),
 				res.UpdateCommand,
-			})
+			}
+			if showAge {
+				row = insertColumn(row, 2, "")
+			}
+			rows = append(rows, row)
+			continue
+		}
+
+		if res.Skipped != "" {
+			row := []string{
+				colorize(r.EnableColor, res.DisplayName(), ansiHiYellow),
+				"skipped",
+				res.Skipped,
+				res.UpdateCommand,
+			}
+			if showAge {
+				row = insertColumn(row, 2, "")
+			}
+			rows = append(rows, row)
 			continue
 		}
 
@@ -81,7 +122,11 @@ func (r Renderer) Render(results []Result) {
 				if idx >= 3 {
 					break
 				}
-				pkgNames = append(pkgNames, item.Name)
+				name := item.Name
+				if age := item.OutdatedFor(now); age >= 24*time.Hour {
+					name += " (" + formatAge(age) + ")"
+				}
+				pkgNames = append(pkgNames, name)
 			}
 			if len(res.Items) > 3 {
 				pkgNames = append(pkgNames, fmt.Sprintf("+%d more", len(res.Items)-3))
@@ -89,12 +134,16 @@ func (r Renderer) Render(results []Result) {
 			pkgList = strings.Join(pkgNames, ", ")
 		}
 
-		rows = append(rows, []string{
-			colorize(r.EnableColor, res.Manager, ansiCyan) + " " + stopwatch(res.DurationMs),
+		row := []string{
+			colorize(r.EnableColor, res.DisplayName(), ansiCyan) + " " + timing(res, now),
 			countText,
 			pkgList,
 			res.UpdateCommand,
-		})
+		}
+		if showAge {
+			row = insertColumn(row, 2, formatAge(res.OldestOutdated(now)))
+		}
+		rows = append(rows, row)
 	}
 
 	widths := computeColumnWidths(rows)
@@ -106,9 +155,224 @@ func (r Renderer) Render(results []Result) {
 		printRow(r.Writer, rows[i], widths)
 	}
 
+	r.renderHints(results, now)
+
 	if totalOutdated == 0 {
 		fmt.Fprintln(r.Writer, "\n🎉 All supported package managers look up to date.")
 	}
+
+	if r.ShowDetection {
+		detections := make([]ManagerDetection, 0, len(results))
+		for _, res := range results {
+			if res.Detection.Available {
+				detections = append(detections, ManagerDetection{Manager: res.Key(), Detection: res.Detection})
+			}
+		}
+		if len(detections) > 0 {
+			fmt.Fprintln(r.Writer)
+			r.RenderDetections(detections)
+		}
+	}
+}
+
+// staleMetadataAge is when unrefreshed metadata is pointed out below the table.
+const staleMetadataAge = 24 * time.Hour
+
+// renderHints lists the remediation hint of every failed manager below the
+// table, since the table itself only has room for a short message, and warns
+// about results based on old or failed-to-refresh metadata.
+func (r Renderer) renderHints(results []Result, now time.Time) {
+	printed := false
+	note := func(label string, res Result, text string) {
+		if !printed {
+			fmt.Fprintln(r.Writer)
+			printed = true
+		}
+		fmt.Fprintf(r.Writer, "%s %s: %s\n", colorize(r.EnableColor, label, ansiHiYellow), res.DisplayName(), text)
+	}
+
+	for _, res := range results {
+		if hint := ErrorHint(res.Err); hint != "" {
+			note("hint", res, hint)
+		}
+		switch {
+		case res.RefreshErr != nil:
+			note("stale", res, "refresh failed, results may be out of date: "+res.RefreshErr.Error())
+		case !res.Refreshed && !res.MetadataUpdated.IsZero() && now.Sub(res.MetadataUpdated) > staleMetadataAge:
+			note("stale", res, "metadata last fetched "+formatAge(now.Sub(res.MetadataUpdated))+" ago; run with --refresh")
+		}
+	}
+}
+
+// RenderDetections prints where each manager was found, its version and
+// install scope, or why it is unavailable.
+func (r Renderer) RenderDetections(detections []ManagerDetection) {
+	if r.Writer == nil {
+		return
+	}
+
+	rows := [][]string{{"Manager", "Version", "Scope", "Path / Reason"}}
+	for _, d := range detections {
+		name := Result{Manager: d.Manager, Detection: d.Detection}.DisplayName()
+		if d.Detection.DuplicateOf != "" {
+			rows = append(rows, []string{
+				name,
+				colorize(r.EnableColor, "merged", ansiGreen),
+				d.Detection.Scope,
+				d.Detection.Reason,
+			})
+			continue
+		}
+		if !d.Detection.Available {
+			rows = append(rows, []string{
+				colorize(r.EnableColor, name, ansiHiYellow),
+				colorize(r.EnableColor, "missing", ansiRed),
+				"",
+				d.Detection.Reason,
+			})
+			continue
+		}
+		version := d.Detection.Version
+		if version == "" {
+			version = "?"
+		}
+		rows = append(rows, []string{
+			colorize(r.EnableColor, name, ansiCyan),
+			version,
+			d.Detection.Scope,
+			d.Detection.Path,
+		})
+	}
+
+	widths := computeColumnWidths(rows)
+	printRow(r.Writer, rows[0], widths)
+	printSeparator(r.Writer, widths)
+	for i := 1; i < len(rows); i++ {
+		printRow(r.Writer, rows[i], widths)
+	}
+}
+
+// RenderHistory prints a one-line summary per stored scan.
+func (r Renderer) RenderHistory(records []ScanRecord) {
+	if r.Writer == nil {
+		return
+	}
+
+	if len(records) == 0 {
+		fmt.Fprintln(r.Writer, "No scans recorded yet.")
+		return
+	}
+
+	rows := [][]string{{"ID", "Time", "Host", "Managers", "Outdated", "Errors", "Duration"}}
+	for _, rec := range records {
+		outdated := fmt.Sprintf("%d", rec.Outdated())
+		if rec.Outdated() > 0 {
+			outdated = colorize(r.EnableColor, outdated, ansiHiMagenta)
+		}
+		errCount := fmt.Sprintf("%d", rec.Errors())
+		if rec.Errors() > 0 {
+			errCount = colorize(r.EnableColor, errCount, ansiRed)
+		}
+		rows = append(rows, []string{
+			rec.ID,
+			rec.Time.Local().Format("2006-01-02 15:04:05"),
+			rec.Hostname,
+			fmt.Sprintf("%d", len(rec.Results)),
+			outdated,
+			errCount,
+			(time.Duration(rec.DurationMs) * time.Millisecond).String(),
+		})
+	}
+
+	widths := computeColumnWidths(rows)
+	printRow(r.Writer, rows[0], widths)
+	printSeparator(r.Writer, widths)
+	for i := 1; i < len(rows); i++ {
+		printRow(r.Writer, rows[i], widths)
+	}
+}
+
+// RenderDiff prints the changes between two scans.
+func (r Renderer) RenderDiff(d ScanDiff) {
+	if r.Writer == nil {
+		return
+	}
+
+	if r.Timestamp {
+		fmt.Fprintf(r.Writer, "\n[%s]\n", time.Now().Format(time.RFC3339))
+	}
+
+	if d.Empty() {
+		fmt.Fprintf(r.Writer, "No changes since %s.\n", d.From.Time.Local().Format("2006-01-02 15:04:05"))
+		return
+	}
+
+	rows := [][]string{{"Change", "Manager", "Package", "Version"}}
+	for _, c := range d.NewlyOutdated {
+		rows = append(rows, []string{
+			colorize(r.EnableColor, "outdated", ansiHiMagenta),
+			c.Manager,
+			c.Item.Name,
+			versionSpan(c.Item.Current, c.Item.Latest),
+		})
+	}
+	for _, c := range d.Bumped {
+		rows = append(rows, []string{
+			colorize(r.EnableColor, "bumped", ansiHiYellow),
+			c.Manager,
+			c.Item.Name,
+			versionSpan(c.PreviousLatest, c.Item.Latest),
+		})
+	}
+	for _, c := range d.Resolved {
+		rows = append(rows, []string{
+			colorize(r.EnableColor, "resolved", ansiGreen),
+			c.Manager,
+			c.Item.Name,
+			"",
+		})
+	}
+	for _, c := range d.NewErrors {
+		rows = append(rows, []string{
+			colorize(r.EnableColor, "failing", ansiRed),
+			c.Manager,
+			truncate(c.Error, 60),
+			c.Kind,
+		})
+	}
+	for _, c := range d.ClearedErrors {
+		rows = append(rows, []string{
+			colorize(r.EnableColor, "recovered", ansiGreen),
+			c.Manager,
+			"",
+			"",
+		})
+	}
+
+	widths := computeColumnWidths(rows)
+	printRow(r.Writer, rows[0], widths)
+	printSeparator(r.Writer, widths)
+	for i := 1; i < len(rows); i++ {
+		printRow(r.Writer, rows[i], widths)
+	}
+}
+
+func versionSpan(from, to string) string {
+	switch {
+	case from == "":
+		return to
+	case to == "":
+		return from
+	default:
+		return from + " → " + to
+	}
+}
+
+func insertColumn(row []string, idx int, cell string) []string {
+	out := make([]string, 0, len(row)+1)
+	out = append(out, row[:idx]...)
+	out = append(out, cell)
+	return append(out, row[idx:]...)
 }
 
 func printSeparator(w io.Writer, widths []int) {
@@ -156,6 +420,17 @@ func colorize(enabled bool, text, code string) string {
 	return code + text + ansiReset
 }
 
+// timing describes how a row was obtained: its check duration, or the cache age.
+func timing(res Result, now time.Time) string {
+	if !res.CachedAt.IsZero() {
+		return fmt.Sprintf("(cached %s ago)", now.Sub(res.CachedAt).Round(time.Second))
+	}
+	if res.Attempts > 1 {
+		return fmt.Sprintf("(%dms, %d attempts)", res.DurationMs, res.Attempts)
+	}
+	return stopwatch(res.DurationMs)
+}
+
 func stopwatch(durationMs int64) string {
 	if durationMs <= 0 {
 		return ""
@@ -0,0 +1,260 @@
+package upd8
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/url"
+	"path"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// rootFlatpakManager compares the apps deployed in the tree's flatpak
+// installations with the appstream data of their remotes, which flatpak
+// keeps next to them, so the check needs no network.
+type rootFlatpakManager struct {
+	fs rootFS
+}
+
+var rootFlatpakDirs = []string{"/var/lib/flatpak", "/root/.local/share/flatpak", "/home/*/.local/share/flatpak"}
+
+func (m *rootFlatpakManager) Name() string { return "flatpak" }
+
+func (m *rootFlatpakManager) Detect(context.Context) Detection {
+	for _, dir := range rootFlatpakDirs {
+		if matches := m.fs.Glob(dir + "/app"); len(matches) > 0 {
+			return Detection{Available: true, Path: matches[0], Scope: "system"}
+		}
+	}
+	return Detection{Reason: "no flatpak installation in " + m.fs.root}
+}
+
+func (m *rootFlatpakManager) CheckOffline(ctx context.Context) Result {
+	return m.CheckUpdates(ctx)
+}
+
+func (m *rootFlatpakManager) CheckUpdates(ctx context.Context) Result {
+	r := Result{Manager: m.Name(), UpdateCommand: "flatpak update"}
+	for _, pattern := range rootFlatpakDirs {
+		for _, dir := range m.fs.Glob(pattern) {
+			if ctx.Err() != nil {
+				r.Err = ctx.Err()
+				return r
+			}
+			latest := m.appstreamVersions(dir)
+			// app/<id>/<arch>/<branch>/active
+			for _, active := range m.fs.Glob(dir + "/app/*/*/*/active") {
+				id := path.Base(path.Dir(path.Dir(path.Dir(active))))
+				current := m.installedVersion(active, id)
+				if v, ok := latest[id]; ok && current != "" && compareVersions(v, current) > 0 {
+					r.Items = append(r.Items, Item{Name: id, Current: current, Latest: v})
+				}
+			}
+		}
+	}
+	sort.Slice(r.Items, func(i, j int) bool { return r.Items[i].Name < r.Items[j].Name })
+	return r
+}
+
+// installedVersion returns the newest release in the app's own metainfo.
+func (m *rootFlatpakManager) installedVersion(active, id string) string {
+	for _, p := range []string{
+		"/files/share/metainfo/" + id + ".metainfo.xml",
+		"/files/share/metainfo/" + id + ".appdata.xml",
+		"/files/share/appdata/" + id + ".appdata.xml",
+	} {
+		data, err := m.fs.ReadFile(active + p)
+		if err != nil {
+			continue
+		}
+		var doc appstreamComponent
+		if xml.Unmarshal(data, &doc) == nil && len(doc.Releases) > 0 {
+			return doc.Releases[0].Version
+		}
+	}
+	return ""
+}
+
+// appstreamVersions returns the newest release of every app in the
+// appstream data of all remotes of the installation at dir.
+func (m *rootFlatpakManager) appstreamVersions(dir string) map[string]string {
+	versions := map[string]string{}
+	files := append(m.fs.Glob(dir+"/appstream/*/*/active/appstream.xml.gz"), m.fs.Glob(dir+"/appstream/*/*/active/appstream.xml")...)
+	for _, file := range files {
+		data, err := m.fs.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var rd io.Reader = bytes.NewReader(data)
+		if strings.HasSuffix(file, ".gz") {
+			gz, err := gzip.NewReader(rd)
+			if err != nil {
+				continue
+			}
+			rd = gz
+		}
+		var doc struct {
+			Components []appstreamComponent `xml:"component"`
+		}
+		if xml.NewDecoder(rd).Decode(&doc) != nil {
+			continue
+		}
+		for _, c := range doc.Components {
+			id := strings.TrimSuffix(c.ID, ".desktop")
+			if len(c.Releases) == 0 {
+				continue
+			}
+			if v := c.Releases[0].Version; compareVersions(v, versions[id]) > 0 {
+				versions[id] = v
+			}
+		}
+	}
+	return versions
+}
+
+// appstreamComponent is the part of an appstream component upd8 reads.
+// Releases are listed newest first.
+type appstreamComponent struct {
+	ID       string `xml:"id"`
+	Releases []struct {
+		Version string `xml:"version,attr"`
+	} `xml:"releases>release"`
+}
+
+// rootSnapManager reads snapd's state in the tree and asks the snap store
+// for the revision on each snap's tracked channel.
+type rootSnapManager struct {
+	fs  rootFS
+	reg *registry
+	url string
+}
+
+const snapdState = "/var/lib/snapd/state.json"
+
+func (m *rootSnapManager) Name() string { return "snap" }
+
+func (m *rootSnapManager) Detect(context.Context) Detection {
+	return rootDetection(m.fs, "snapd", snapdState)
+}
+
+func (m *rootSnapManager) CheckUpdates(ctx context.Context) Result {
+	r := Result{Manager: m.Name(), UpdateCommand: "snap refresh"}
+	data, err := m.fs.ReadFile(snapdState)
+	if err != nil {
+		r.Err = &CheckError{Kind: ErrNotInstalled, Op: "read " + snapdState, Err: err}
+		return r
+	}
+	var state struct {
+		Data struct {
+			Snaps map[string]struct {
+				Current         json.Number `json:"current"`
+				TrackingChannel string      `json:"tracking-channel"`
+				Channel         string      `json:"channel"`
+			} `json:"snaps"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		r.Err = parseError("read "+snapdState, err, nil)
+		return r
+	}
+
+	var pkgs []installedPackage
+	revisions := map[string]int{}
+	channels := map[string]string{}
+	for name, s := range state.Data.Snaps {
+		rev, err := strconv.Atoi(s.Current.String())
+		if err != nil {
+			// Local snaps have "x1"-style revisions and no store to ask.
+			continue
+		}
+		revisions[name] = rev
+		channels[name] = s.TrackingChannel
+		if channels[name] == "" {
+			channels[name] = s.Channel
+		}
+		pkgs = append(pkgs, installedPackage{name: name, version: m.snapVersion(name, rev)})
+	}
+
+	r.Items, r.Err = outdatedItems(ctx, pkgs, func(ctx context.Context, pkg installedPackage) (*Item, error) {
+		var doc struct {
+			ChannelMap []struct {
+				Channel struct {
+					Architecture string `json:"architecture"`
+					Name         string `json:"name"`
+					Risk         string `json:"risk"`
+					Track        string `json:"track"`
+				} `json:"channel"`
+				Revision int    `json:"revision"`
+				Version  string `json:"version"`
+			} `json:"channel-map"`
+		}
+		headers := map[string]string{"Snap-Device-Series": "16"}
+		u := m.url + "/" + url.PathEscape(pkg.name) + "?fields=version,revision"
+		if err := m.reg.getJSON(ctx, u, headers, &doc); err != nil {
+			return nil, err
+		}
+		track, risk := splitSnapChannel(channels[pkg.name])
+		for _, e := range doc.ChannelMap {
+			if e.Channel.Architecture != snapArch() || e.Channel.Track != track || e.Channel.Risk != risk {
+				continue
+			}
+			if e.Revision > revisions[pkg.name] {
+				return &Item{Name: pkg.name, Current: pkg.version, Latest: e.Version}, nil
+			}
+			return nil, nil
+		}
+		return nil, nil
+	})
+	return r
+}
+
+// snapVersion returns the version of the mounted revision, or "r<rev>"
+// when the image does not have the snap mounted.
+func (m *rootSnapManager) snapVersion(name string, rev int) string {
+	data, err := m.fs.ReadFile("/snap/" + name + "/" + strconv.Itoa(rev) + "/meta/snap.yaml")
+	if err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if v, ok := strings.CutPrefix(line, "version:"); ok {
+				return strings.Trim(strings.TrimSpace(v), `'"`)
+			}
+		}
+	}
+	return "r" + strconv.Itoa(rev)
+}
+
+// splitSnapChannel splits "latest/stable", "stable" or "3.x/edge/branch"
+// into track and risk.
+func splitSnapChannel(channel string) (string, string) {
+	parts := strings.Split(channel, "/")
+	switch {
+	case channel == "":
+		return "latest", "stable"
+	case len(parts) == 1:
+		return "latest", parts[0]
+	default:
+		return parts[0], parts[1]
+	}
+}
+
+// snapArch returns the snap architecture name of the running machine; images
+// are assumed to match it.
+func snapArch() string {
+	switch runtime.GOARCH {
+	case "amd64", "arm64", "s390x", "riscv64":
+		return runtime.GOARCH
+	case "arm":
+		return "armhf"
+	case "386":
+		return "i386"
+	case "ppc64le":
+		return "ppc64el"
+	default:
+		return runtime.GOARCH
+	}
+}
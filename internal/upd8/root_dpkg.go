@@ -0,0 +1,265 @@
+package upd8
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// rootDpkgManager compares the dpkg status database of a tree with the apt
+// package lists stored in it, or with AptLists from a mirror.
+type rootDpkgManager struct {
+	fs    rootFS
+	lists string
+}
+
+const dpkgStatus = "/var/lib/dpkg/status"
+
+func (m *rootDpkgManager) Name() string { return "dpkg" }
+
+func (m *rootDpkgManager) Detect(context.Context) Detection {
+	return rootDetection(m.fs, "dpkg", dpkgStatus)
+}
+
+var rootDpkgHints = Hints{
+	ErrPrerequisite: "the image has no apt lists; set root.apt_lists to a directory of Packages files from a mirror",
+}
+
+func (m *rootDpkgManager) CheckOffline(ctx context.Context) Result {
+	return m.CheckUpdates(ctx)
+}
+
+func (m *rootDpkgManager) CheckUpdates(ctx context.Context) Result {
+	r := Result{Manager: m.Name(), UpdateCommand: "apt upgrade"}
+
+	f, err := m.fs.Open(dpkgStatus)
+	if err != nil {
+		r.Err = &CheckError{Kind: ErrNotInstalled, Op: "read " + dpkgStatus, Err: err}
+		return r
+	}
+	installed := map[string]debPackage{}
+	err = readDebParagraphs(f, func(p debPackage) {
+		if strings.HasSuffix(p.status, " installed") {
+			installed[p.name+":"+p.arch] = p
+		}
+	})
+	f.Close()
+	if err != nil {
+		r.Err = parseError("read "+dpkgStatus, err, nil)
+		return r
+	}
+
+	lists := m.packageLists()
+	if len(lists) == 0 {
+		r.Err = &CheckError{Kind: ErrPrerequisite, Op: "read apt lists", Err: errors.New("no Packages files found"), Hint: rootDpkgHints[ErrPrerequisite]}
+		return r
+	}
+	candidates := map[string]string{}
+	for _, open := range lists {
+		if ctx.Err() != nil {
+			r.Err = ctx.Err()
+			return r
+		}
+		f, err := open()
+		if err != nil {
+			continue
+		}
+		err = readDebParagraphs(f, func(p debPackage) {
+			key := p.name + ":" + p.arch
+			if cur, ok := candidates[key]; !ok || compareDebVersions(p.version, cur) > 0 {
+				candidates[key] = p.version
+			}
+		})
+		f.Close()
+		if err != nil {
+			r.Err = parseError("read apt lists", err, nil)
+			return r
+		}
+	}
+
+	for _, pkg := range installed {
+		latest, ok := candidates[pkg.name+":"+pkg.arch]
+		if !ok {
+			latest, ok = candidates[pkg.name+":all"]
+		}
+		if ok && compareDebVersions(latest, pkg.version) > 0 {
+			r.Items = append(r.Items, Item{Name: pkg.name, Current: pkg.version, Latest: latest})
+		}
+	}
+	sort.Slice(r.Items, func(i, j int) bool { return r.Items[i].Name < r.Items[j].Name })
+	return r
+}
+
+// packageLists returns openers for the Packages files to compare against.
+func (m *rootDpkgManager) packageLists() []func() (io.ReadCloser, error) {
+	var lists []func() (io.ReadCloser, error)
+	if m.lists != "" {
+		home, _ := os.UserHomeDir()
+		files, _ := filepath.Glob(filepath.Join(expandHome(m.lists, home), "*Packages"))
+		for _, file := range files {
+			file := file
+			lists = append(lists, func() (io.ReadCloser, error) { return os.Open(file) })
+		}
+		return lists
+	}
+	for _, file := range m.fs.Glob("/var/lib/apt/lists/*_Packages") {
+		file := file
+		lists = append(lists, func() (io.ReadCloser, error) { return m.fs.Open(file) })
+	}
+	return lists
+}
+
+// debPackage holds the fields of a dpkg status or apt Packages paragraph
+// that matter here.
+type debPackage struct {
+	name, version, arch, status string
+}
+
+// readDebParagraphs calls fn for every paragraph of a Debian control file.
+func readDebParagraphs(r io.Reader, fn func(debPackage)) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	var p debPackage
+	flush := func() {
+		if p.name != "" && p.version != "" {
+			fn(p)
+		}
+		p = debPackage{}
+	}
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Package":
+			p.name = value
+		case "Version":
+			p.version = value
+		case "Architecture":
+			p.arch = value
+		case "Status":
+			p.status = value
+		}
+	}
+	flush()
+	return sc.Err()
+}
+
+// compareDebVersions orders Debian versions ([epoch:]upstream[-revision])
+// the way dpkg does.
+func compareDebVersions(a, b string) int {
+	ea, ua, ra := splitDebVersion(a)
+	eb, ub, rb := splitDebVersion(b)
+	if ea != eb {
+		if ea < eb {
+			return -1
+		}
+		return 1
+	}
+	if c := compareDebPart(ua, ub); c != 0 {
+		return c
+	}
+	return compareDebPart(ra, rb)
+}
+
+func splitDebVersion(v string) (int, string, string) {
+	epoch := 0
+	if e, rest, ok := strings.Cut(v, ":"); ok {
+		epoch, _ = strconv.Atoi(e)
+		v = rest
+	}
+	revision := ""
+	if i := strings.LastIndex(v, "-"); i >= 0 {
+		v, revision = v[:i], v[i+1:]
+	}
+	return epoch, v, revision
+}
+
+// compareDebPart compares alternating non-digit and digit runs; in the
+// non-digit runs "~" sorts before everything, even the end of the string.
+func compareDebPart(a, b string) int {
+	for a != "" || b != "" {
+		for (a != "" && !isDigit(a[0])) || (b != "" && !isDigit(b[0])) {
+			ca, cb := debOrder(a), debOrder(b)
+			if ca != cb {
+				if ca < cb {
+					return -1
+				}
+				return 1
+			}
+			a, b = a[min(1, len(a)):], b[min(1, len(b)):]
+		}
+		var na, nb int
+		for a != "" && isDigit(a[0]) {
+			na = na*10 + int(a[0]-'0')
+			a = a[1:]
+		}
+		for b != "" && isDigit(b[0]) {
+			nb = nb*10 + int(b[0]-'0')
+			b = b[1:]
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func debOrder(s string) int {
+	switch {
+	case s == "" || isDigit(s[0]):
+		return 0
+	case s[0] == '~':
+		return -1
+	case s[0] >= 'a' && s[0] <= 'z' || s[0] >= 'A' && s[0] <= 'Z':
+		return int(s[0])
+	default:
+		return int(s[0]) + 256
+	}
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// rootRPMManager reports an rpm database it finds as skipped, so an image
+// whose system packages were not audited says so: reading the database needs
+// rpm itself, which --root never runs.
+type rootRPMManager struct {
+	fs rootFS
+}
+
+func (m *rootRPMManager) Name() string { return "rpm" }
+
+func (m *rootRPMManager) Detect(context.Context) Detection {
+	for _, dir := range []string{"/var/lib/rpm", "/usr/lib/sysimage/rpm"} {
+		if m.fs.Exists(dir) {
+			return Detection{Available: true, Path: dir, Scope: "system"}
+		}
+	}
+	return Detection{Reason: "no rpm database in " + m.fs.root}
+}
+
+func (m *rootRPMManager) CheckUpdates(context.Context) Result {
+	return Result{Manager: m.Name(), Skipped: "rpm database not audited: reading it needs rpm, which --root does not run"}
+}
+
+// CheckOffline is CheckUpdates: nothing is looked up either way.
+func (m *rootRPMManager) CheckOffline(ctx context.Context) Result { return m.CheckUpdates(ctx) }
@@ -0,0 +1,33 @@
+package upd8
+
+import "testing"
+
+func TestCompareDebVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0-1", "1.0-2", -1},
+		{"1.9", "1.10", -1},
+		{"1:0.9", "2.0", 1},
+		{"0:1.0", "1.0", 0},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~~", "1.0~", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0", "1.0a", -1},
+		{"1.0a", "1.0+", -1},
+		{"1.0", "1.0.1", -1},
+		{"2.36-9+deb12u4", "2.36-9+deb12u10", -1},
+		{"1.2.3-1ubuntu1", "1.2.3-1", 1},
+		{"7.88.1-10+deb12u5", "7.88.1-10", 1},
+	}
+	for _, tt := range tests {
+		if got := compareDebVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareDebVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := compareDebVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("compareDebVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package upd8
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/textproto"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// rootPipManager reads the *.dist-info directories pip and uv leave in the
+// tree's site-packages and asks PyPI for the latest releases.
+type rootPipManager struct {
+	fs  rootFS
+	reg *registry
+	url string
+}
+
+// rootSitePackages are the site-packages patterns searched in the tree.
+var rootSitePackages = []string{
+	"/usr/lib/python3*/site-packages",
+	"/usr/lib64/python3*/site-packages",
+	"/usr/local/lib/python3*/site-packages",
+	"/usr/local/lib/python3*/dist-packages",
+	"/root/.local/lib/python3*/site-packages",
+	"/home/*/.local/lib/python3*/site-packages",
+}
+
+func (m *rootPipManager) Name() string { return "pip" }
+
+func (m *rootPipManager) Detect(context.Context) Detection {
+	if len(m.distInfos()) == 0 {
+		return Detection{Reason: "no pip-installed packages in " + m.fs.root}
+	}
+	return Detection{Available: true, Path: "site-packages", Scope: "system"}
+}
+
+// distInfos returns the dist-info directories of packages installed by pip
+// or uv. Distribution packages have INSTALLER "debian" or "rpm" or none, and
+// belong to the system package manager.
+func (m *rootPipManager) distInfos() []string {
+	var dirs []string
+	for _, site := range rootSitePackages {
+		for _, dir := range m.fs.Glob(site + "/*.dist-info") {
+			installer, err := m.fs.ReadFile(dir + "/INSTALLER")
+			if err != nil {
+				continue
+			}
+			switch strings.TrimSpace(string(installer)) {
+			case "pip", "uv":
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs
+}
+
+func (m *rootPipManager) CheckUpdates(ctx context.Context) Result {
+	r := Result{Manager: m.Name(), UpdateCommand: "pip install --upgrade"}
+	seen := map[string]bool{}
+	var pkgs []installedPackage
+	for _, dir := range m.distInfos() {
+		data, err := m.fs.ReadFile(dir + "/METADATA")
+		if err != nil {
+			continue
+		}
+		hdr, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(data))).ReadMIMEHeader()
+		if err != nil && len(hdr) == 0 {
+			continue
+		}
+		name, version := hdr.Get("Name"), hdr.Get("Version")
+		if name == "" || version == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		pkgs = append(pkgs, installedPackage{name: name, version: version})
+	}
+
+	r.Items, r.Err = outdatedItems(ctx, pkgs, func(ctx context.Context, pkg installedPackage) (*Item, error) {
+		var doc struct {
+			Info struct {
+				Version string `json:"version"`
+			} `json:"info"`
+		}
+		if err := m.reg.getJSON(ctx, m.url+"/"+normalizePyPIName(pkg.name)+"/json", nil, &doc); err != nil {
+			return nil, err
+		}
+		return newerItem(pkg, doc.Info.Version), nil
+	})
+	return r
+}
+
+var pypiNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// normalizePyPIName normalizes a project name as PEP 503 does.
+func normalizePyPIName(name string) string {
+	return strings.ToLower(pypiNameSeparators.ReplaceAllString(name, "-"))
+}
+
+// rootNpmManager reads the package.json of globally installed npm packages
+// and asks the registry for their "latest" dist-tag.
+type rootNpmManager struct {
+	fs  rootFS
+	reg *registry
+	url string
+}
+
+// rootNodeModules are the global node_modules patterns searched in the tree.
+var rootNodeModules = []string{
+	"/usr/lib/node_modules",
+	"/usr/local/lib/node_modules",
+	"/root/.nvm/versions/node/*/lib/node_modules",
+	"/home/*/.nvm/versions/node/*/lib/node_modules",
+}
+
+func (m *rootNpmManager) Name() string { return "npm" }
+
+func (m *rootNpmManager) Detect(context.Context) Detection {
+	for _, dir := range rootNodeModules {
+		if matches := m.fs.Glob(dir); len(matches) > 0 {
+			return Detection{Available: true, Path: matches[0], Scope: "system"}
+		}
+	}
+	return Detection{Reason: "no global node_modules in " + m.fs.root}
+}
+
+func (m *rootNpmManager) CheckUpdates(ctx context.Context) Result {
+	r := Result{Manager: m.Name(), UpdateCommand: "npm update -g"}
+	seen := map[string]bool{}
+	var pkgs []installedPackage
+	for _, dir := range rootNodeModules {
+		manifests := append(m.fs.Glob(dir+"/*/package.json"), m.fs.Glob(dir+"/@*/*/package.json")...)
+		for _, manifest := range manifests {
+			data, err := m.fs.ReadFile(manifest)
+			if err != nil {
+				continue
+			}
+			var pkg struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			}
+			if json.Unmarshal(data, &pkg) != nil || pkg.Name == "" || pkg.Version == "" {
+				continue
+			}
+			// npm itself and corepack ship with node and are upgraded with it.
+			if seen[pkg.Name] || pkg.Name == "npm" || pkg.Name == "corepack" {
+				continue
+			}
+			seen[pkg.Name] = true
+			pkgs = append(pkgs, installedPackage{name: pkg.Name, version: pkg.Version})
+		}
+	}
+
+	r.Items, r.Err = outdatedItems(ctx, pkgs, func(ctx context.Context, pkg installedPackage) (*Item, error) {
+		var tags map[string]string
+		if err := m.reg.getJSON(ctx, m.url+"/-/package/"+url.PathEscape(pkg.name)+"/dist-tags", nil, &tags); err != nil {
+			return nil, err
+		}
+		return newerItem(pkg, tags["latest"]), nil
+	})
+	return r
+}
+
+// rootCargoManager reads the .crates2.json cargo install keeps in each
+// CARGO_HOME and asks crates.io for the newest stable versions.
+type rootCargoManager struct {
+	fs  rootFS
+	reg *registry
+	url string
+}
+
+var rootCargoHomes = []string{"/root/.cargo", "/home/*/.cargo"}
+
+func (m *rootCargoManager) Name() string { return "cargo" }
+
+func (m *rootCargoManager) Detect(context.Context) Detection {
+	for _, home := range rootCargoHomes {
+		if matches := m.fs.Glob(home + "/.crates2.json"); len(matches) > 0 {
+			return Detection{Available: true, Path: matches[0], Scope: "user"}
+		}
+	}
+	return Detection{Reason: "no cargo install records in " + m.fs.root}
+}
+
+func (m *rootCargoManager) CheckUpdates(ctx context.Context) Result {
+	r := Result{Manager: m.Name(), UpdateCommand: "cargo install-update -a"}
+	seen := map[string]bool{}
+	var pkgs []installedPackage
+	for _, home := range rootCargoHomes {
+		for _, file := range m.fs.Glob(home + "/.crates2.json") {
+			data, err := m.fs.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			var doc struct {
+				Installs map[string]json.RawMessage `json:"installs"`
+			}
+			if err := json.Unmarshal(data, &doc); err != nil {
+				r.Err = parseError("read "+file, err, nil)
+				return r
+			}
+			for key := range doc.Installs {
+				// "ripgrep 14.1.0 (registry+https://github.com/rust-lang/crates.io-index)"
+				fields := strings.Fields(key)
+				if len(fields) != 3 || !strings.Contains(fields[2], "crates.io-index") || seen[fields[0]] {
+					continue
+				}
+				seen[fields[0]] = true
+				pkgs = append(pkgs, installedPackage{name: fields[0], version: fields[1]})
+			}
+		}
+	}
+
+	r.Items, r.Err = outdatedItems(ctx, pkgs, func(ctx context.Context, pkg installedPackage) (*Item, error) {
+		var doc struct {
+			Crate struct {
+				MaxStable string `json:"max_stable_version"`
+				Max       string `json:"max_version"`
+			} `json:"crate"`
+		}
+		if err := m.reg.getJSON(ctx, m.url+"/"+url.PathEscape(pkg.name), nil, &doc); err != nil {
+			return nil, err
+		}
+		latest := doc.Crate.MaxStable
+		if latest == "" {
+			latest = doc.Crate.Max
+		}
+		return newerItem(pkg, latest), nil
+	})
+	return r
+}
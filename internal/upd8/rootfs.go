@@ -0,0 +1,402 @@
+package upd8
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RootConfig controls where `--root` inspection looks up the latest versions
+// of what it finds installed in the image.
+type RootConfig struct {
+	// AptLists is a directory of apt Packages files to compare dpkg packages
+	// against instead of the image's own /var/lib/apt/lists.
+	AptLists string `json:"apt_lists"`
+	// PyPIURL is a PyPI-compatible JSON API, e.g. a mirror.
+	PyPIURL string `json:"pypi_url"`
+	// NPMRegistry is the npm registry to ask for dist-tags.
+	NPMRegistry string `json:"npm_registry"`
+	// CratesURL is the crates.io-compatible API for cargo packages.
+	CratesURL string `json:"crates_url"`
+	// SnapStoreURL is the snap store API.
+	SnapStoreURL string `json:"snap_store_url"`
+	// Timeout bounds each registry request.
+	Timeout Duration `json:"timeout"`
+}
+
+// Defaults for RootConfig fields left empty.
+const (
+	defaultPyPIURL      = "https://pypi.org/pypi"
+	defaultNPMRegistry  = "https://registry.npmjs.org"
+	defaultCratesURL    = "https://crates.io/api/v1/crates"
+	defaultSnapStoreURL = "https://api.snapcraft.io/v2/snaps/info"
+)
+
+// registryWorkers bounds concurrent registry requests per manager.
+const registryWorkers = 8
+
+// RootManagers returns managers that read installed packages from the on-disk
+// databases of the filesystem tree at root and never run anything inside it.
+// dpkg and flatpak compare against repository metadata stored in the tree;
+// the others ask their registries.
+func RootManagers(root string, cfg Config) []Manager {
+	fs := rootFS{root: root}
+	reg := newRegistry(time.Duration(cfg.Root.Timeout))
+	rc := cfg.Root
+	return []Manager{
+		&rootDpkgManager{fs: fs, lists: rc.AptLists},
+		&rootRPMManager{fs: fs},
+		&rootPipManager{fs: fs, reg: reg, url: orDefault(rc.PyPIURL, defaultPyPIURL)},
+		&rootNpmManager{fs: fs, reg: reg, url: orDefault(rc.NPMRegistry, defaultNPMRegistry)},
+		&rootCargoManager{fs: fs, reg: reg, url: orDefault(rc.CratesURL, defaultCratesURL)},
+		&rootFlatpakManager{fs: fs},
+		&rootSnapManager{fs: fs, reg: reg, url: orDefault(rc.SnapStoreURL, defaultSnapStoreURL)},
+	}
+}
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return strings.TrimRight(v, "/")
+}
+
+// maxSymlinks bounds symlink resolution inside a root, as the kernel does.
+const maxSymlinks = 40
+
+// rootFS reads files of a tree mounted at root. Paths are absolute paths as
+// seen from inside the tree, and symlinks are resolved against root so that
+// absolute links in an image never point at the host's files.
+type rootFS struct {
+	root string
+}
+
+// resolve maps p inside the tree to a host path.
+func (fs rootFS) resolve(p string) (string, error) {
+	todo := strings.Split(strings.TrimPrefix(path.Clean("/"+p), "/"), "/")
+	done := "/"
+	links := 0
+	for len(todo) > 0 {
+		part := todo[0]
+		todo = todo[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			done = path.Dir(done)
+			continue
+		}
+		next := path.Join(done, part)
+		target, err := os.Readlink(filepath.Join(fs.root, filepath.FromSlash(next)))
+		if err != nil {
+			// Not a symlink, or missing; the caller finds out which.
+			done = next
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return "", fmt.Errorf("%s: too many levels of symbolic links", p)
+		}
+		if path.IsAbs(target) {
+			done = "/"
+		}
+		todo = append(strings.Split(target, "/"), todo...)
+	}
+	return filepath.Join(fs.root, filepath.FromSlash(done)), nil
+}
+
+func (fs rootFS) ReadFile(p string) ([]byte, error) {
+	host, err := fs.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(host)
+}
+
+func (fs rootFS) Open(p string) (*os.File, error) {
+	host, err := fs.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(host)
+}
+
+func (fs rootFS) Exists(p string) bool {
+	host, err := fs.resolve(p)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(host)
+	return err == nil
+}
+
+// Readlink returns the target of a symlink inside the tree.
+func (fs rootFS) Readlink(p string) (string, error) {
+	dir, err := fs.resolve(path.Dir(p))
+	if err != nil {
+		return "", err
+	}
+	return os.Readlink(filepath.Join(dir, path.Base(p)))
+}
+
+// Glob matches pattern inside the tree and returns sorted in-tree paths.
+// The directory before the first wildcard is resolved like any other path;
+// matched entries are read through resolve again by the callers.
+func (fs rootFS) Glob(pattern string) []string {
+	pattern = path.Clean("/" + pattern)
+	prefix := pattern
+	for strings.ContainsAny(prefix, "*?[") {
+		prefix = path.Dir(prefix)
+	}
+	base, err := fs.resolve(prefix)
+	if err != nil {
+		return nil
+	}
+	rest := strings.TrimPrefix(pattern, prefix)
+	matches, _ := filepath.Glob(base + filepath.FromSlash(rest))
+	out := make([]string, 0, len(matches))
+	for _, m := range matches {
+		rel, err := filepath.Rel(base, m)
+		if err != nil {
+			continue
+		}
+		out = append(out, path.Join(prefix, filepath.ToSlash(rel)))
+	}
+	sort.Strings(out)
+	return out
+}
+
+// rootDetection reports a manager as available when any of its database
+// paths exists in the tree.
+func rootDetection(fs rootFS, name string, paths ...string) Detection {
+	for _, p := range paths {
+		if fs.Exists(p) {
+			return Detection{Available: true, Path: p, Scope: "system"}
+		}
+	}
+	return Detection{Reason: "no " + name + " database in " + fs.root}
+}
+
+// registry fetches package metadata over HTTP.
+type registry struct {
+	client *http.Client
+}
+
+func newRegistry(timeout time.Duration) *registry {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &registry{client: &http.Client{Timeout: timeout}}
+}
+
+// errNoSuchPackage is returned for packages the registry does not know,
+// which are skipped rather than failing the whole check.
+var errNoSuchPackage = errors.New("no such package")
+
+// getJSON decodes the JSON document at url into v.
+func (r *registry) getJSON(ctx context.Context, url string, headers map[string]string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "upd8 (https://github.com/makalin/upd8)")
+	req.Header.Set("Accept", "application/json")
+	for k, val := range headers {
+		req.Header.Set(k, val)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &CheckError{Kind: ErrNetwork, Op: "GET " + url, Err: err, Hint: defaultHints[ErrNetwork]}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errNoSuchPackage
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return &CheckError{Kind: ErrNetwork, Op: "GET " + url, Err: errors.New(resp.Status), Hint: "the registry is unavailable; retry later"}
+	case resp.StatusCode != http.StatusOK:
+		return &CheckError{Op: "GET " + url, Err: errors.New(resp.Status)}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, defaultMaxOutput))
+	if err != nil {
+		return &CheckError{Kind: ErrNetwork, Op: "GET " + url, Err: err}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return parseError("GET "+url, err, nil)
+	}
+	return nil
+}
+
+// installedPackage is a package found in the tree.
+type installedPackage struct {
+	name    string
+	version string
+}
+
+// outdatedItems runs lookup for each package, a few at a time, and returns
+// the items it reports as outdated sorted by name. lookup returns nil for a
+// package that is up to date. Packages the registry does not know are
+// skipped; any other failure fails the check.
+func outdatedItems(ctx context.Context, pkgs []installedPackage, lookup func(context.Context, installedPackage) (*Item, error)) ([]Item, error) {
+	var (
+		mu       sync.Mutex
+		items    []Item
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	slots := make(chan struct{}, registryWorkers)
+	for _, pkg := range pkgs {
+		wg.Add(1)
+		go func(pkg installedPackage) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				return
+			}
+			item, err := lookup(ctx, pkg)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case errors.Is(err, errNoSuchPackage):
+			case err != nil:
+				if firstErr == nil {
+					firstErr = err
+				}
+			case item != nil:
+				items = append(items, *item)
+			}
+		}(pkg)
+	}
+	wg.Wait()
+
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items, nil
+}
+
+// newerItem returns an item for pkg when latest is newer than what is
+// installed.
+func newerItem(pkg installedPackage, latest string) *Item {
+	if latest == "" || compareVersions(latest, pkg.version) <= 0 {
+		return nil
+	}
+	return &Item{Name: pkg.name, Current: pkg.version, Latest: latest}
+}
+
+// compareVersions orders dotted versions such as "1.10.2" and "1.9": runs of
+// digits compare numerically and text by versionTags, so a pre-release
+// ("1.0.0-rc1", "2.0b1", "1.0.dev3") sorts before the plain release and a
+// post-release ("2.0.post1") after it.
+func compareVersions(a, b string) int {
+	a, b = strings.TrimPrefix(a, "v"), strings.TrimPrefix(b, "v")
+	for a != "" || b != "" {
+		var pa, pb string
+		pa, a = nextVersionPart(a)
+		pb, b = nextVersionPart(b)
+		if pa == pb {
+			continue
+		}
+		// A missing number counts as 0, so "1.0" equals "1.0.0".
+		if pa == "" && isDigits(pb) {
+			pa = "0"
+		}
+		if pb == "" && isDigits(pa) {
+			pb = "0"
+		}
+		na, errA := strconv.Atoi(pa)
+		nb, errB := strconv.Atoi(pb)
+		switch {
+		case errA == nil && errB == nil:
+			if na == nb {
+				continue
+			}
+			if na < nb {
+				return -1
+			}
+			return 1
+		case pa == "":
+			// "1.0" vs "1.0.post1" is older, "1.0" vs "1.0rc1" newer.
+			if versionTag(pb) > 0 {
+				return -1
+			}
+			return 1
+		case pb == "":
+			if versionTag(pa) > 0 {
+				return 1
+			}
+			return -1
+		case errA == nil:
+			return 1
+		case errB == nil:
+			return -1
+		default:
+			if ta, tb := versionTag(pa), versionTag(pb); ta != tb {
+				if ta < tb {
+					return -1
+				}
+				return 1
+			}
+			return strings.Compare(pa, pb)
+		}
+	}
+	return 0
+}
+
+// versionTags ranks the text in a version against the release itself (0),
+// following PEP 440: dev releases, then alphas, betas and release candidates
+// come before it, post-releases after. Unknown text counts as a pre-release.
+var versionTags = map[string]int{
+	"dev": -4, "a": -3, "alpha": -3, "b": -2, "beta": -2,
+	"c": -1, "rc": -1, "pre": -1, "preview": -1,
+	"post": 1, "rev": 1, "r": 1,
+}
+
+func versionTag(s string) int {
+	if rank, ok := versionTags[strings.ToLower(s)]; ok {
+		return rank
+	}
+	return -1
+}
+
+// isDigits reports whether a part from nextVersionPart is a number; parts
+// are all digits or none.
+func isDigits(part string) bool {
+	return part != "" && part[0] >= '0' && part[0] <= '9'
+}
+
+// nextVersionPart splits off a run of digits or of letters, skipping
+// separators.
+func nextVersionPart(s string) (string, string) {
+	s = strings.TrimLeft(s, ".-_+~")
+	if s == "" {
+		return "", ""
+	}
+	digit := s[0] >= '0' && s[0] <= '9'
+	i := 0
+	for i < len(s) && s[i] != '.' && s[i] != '-' && s[i] != '_' && s[i] != '+' && s[i] != '~' && (s[i] >= '0' && s[i] <= '9') == digit {
+		i++
+	}
+	return s[:i], s[i:]
+}
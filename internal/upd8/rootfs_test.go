@@ -0,0 +1,94 @@
+package upd8
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.9", "1.10", -1},
+		{"1.10.2", "1.9", 1},
+		{"1.0", "1.0.1", -1},
+		{"1.0.0-rc1", "1.0.0", -1},
+		{"2.0b1", "2.0", -1},
+		{"2.0a1", "2.0b1", -1},
+		{"2.0b2", "2.0rc1", -1},
+		{"1.0.dev3", "1.0a1", -1},
+		{"2.0", "2.0.post1", -1},
+		{"2.0.post1", "2.0.post2", -1},
+		{"2.0.post1", "2.0.1", -1},
+		{"2.0rc1", "2.0.post1", -1},
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+		{"1.0", "1.0.0", 0},
+		{"2", "2.0.0", 0},
+		{"1.01", "1.1", 0},
+		{"1.0", "1.0.0.1", -1},
+		{"1.0.0rc1", "1.0", -1},
+		{"1.0", "1.0.0.post1", -1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := compareVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestRootFSResolve(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"usr/lib", "etc"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"lib":          "usr/lib",
+		"etc/abs":      "/usr/lib",
+		"etc/escape":   "../../../../outside",
+		"etc/absetc":   "/etc/../../..",
+		"etc/loop":     "loop",
+		"usr/lib/self": ".",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/usr/lib/x", "usr/lib/x"},
+		{"lib/x", "usr/lib/x"},
+		{"/etc/abs/x", "usr/lib/x"},
+		{"/etc/escape/x", "outside/x"},
+		{"/etc/absetc/x", "x"},
+		{"/../../etc", "etc"},
+		{"/usr/lib/self/self/x", "usr/lib/x"},
+	}
+	fs := rootFS{root: root}
+	for _, tt := range tests {
+		got, err := fs.resolve(tt.path)
+		if err != nil {
+			t.Errorf("resolve(%q): %v", tt.path, err)
+			continue
+		}
+		if want := filepath.Join(root, tt.want); got != want {
+			t.Errorf("resolve(%q) = %q, want %q", tt.path, got, want)
+		}
+	}
+
+	if _, err := fs.resolve("/etc/loop/x"); err == nil {
+		t.Error("resolve of a symlink loop succeeded")
+	}
+}
@@ -5,18 +5,47 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
 	"syscall"
 	"time"
 )
 
+// Defaults for ExecRunner fields left at zero.
+const (
+	defaultTimeout   = 45 * time.Second
+	defaultKillGrace = 5 * time.Second
+	defaultMaxOutput = 16 << 20
+)
+
 // CommandResult contains stdout, stderr, and exit status from a command execution.
 type CommandResult struct {
 	Stdout   []byte
 	Stderr   []byte
 	ExitCode int
 	Error    error
+	// Truncated is set when stdout or stderr exceeded the runner's output cap.
+	Truncated bool
+	Usage     Usage
+}
+
+// Usage is the resources a command consumed. CPU and MaxRSS include only the
+// direct child and are zero where the platform does not report them.
+type Usage struct {
+	Wall   time.Duration
+	CPU    time.Duration
+	MaxRSS int64
+}
+
+// Add combines the usage of two commands run one after the other.
+func (u Usage) Add(o Usage) Usage {
+	u.Wall += o.Wall
+	u.CPU += o.CPU
+	if o.MaxRSS > u.MaxRSS {
+		u.MaxRSS = o.MaxRSS
+	}
+	return u
 }
 
 // CommandRunner executes commands and returns a CommandResult.
@@ -26,35 +55,85 @@ type CommandRunner interface {
 
 // ExecRunner executes commands using the local OS shell.
 type ExecRunner struct {
+	// Timeout bounds each command when ctx carries no deadline of its own.
 	Timeout time.Duration
+	// Env holds extra KEY=VALUE pairs set on top of the inherited environment
+	// and the fixed locale and output policy (see commandEnv).
+	Env []string
+	// KillGrace is how long a timed-out command's process group has between
+	// SIGTERM and SIGKILL; 0 means 5s.
+	KillGrace time.Duration
+	// MaxOutput caps the bytes kept of stdout and of stderr; 0 means 16 MiB.
+	MaxOutput int
+}
+
+// NewExecRunner returns a runner using the configured command timeout, kill
+// grace period and output cap.
+func NewExecRunner(cfg ScanConfig) ExecRunner {
+	return ExecRunner{
+		Timeout:   time.Duration(cfg.Timeout),
+		KillGrace: time.Duration(cfg.KillGrace),
+		MaxOutput: cfg.MaxOutputBytes,
+	}
 }
 
 // Run executes a command with a context-aware timeout and captures stdout/stderr.
 func (r ExecRunner) Run(ctx context.Context, cmd string, args ...string) CommandResult {
 	if r.Timeout <= 0 {
-		r.Timeout = 45 * time.Second
+		r.Timeout = defaultTimeout
 	}
 
-	nCtx, cancel := context.WithTimeout(ctx, r.Timeout)
-	defer cancel()
+	nCtx := ctx
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		nCtx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	if r.KillGrace <= 0 {
+		r.KillGrace = defaultKillGrace
+	}
+	if r.MaxOutput <= 0 {
+		r.MaxOutput = defaultMaxOutput
+	}
 
 	command := exec.CommandContext(nCtx, cmd, args...)
-	var stdout, stderr bytes.Buffer
-	command.Stdout = &stdout
-	command.Stderr = &stderr
+	command.Env = buildEnv(os.Environ(), r.Env)
+	startGroup(command, r.KillGrace)
+	// Grandchildren that inherited stdout must not keep Wait blocked forever.
+	command.WaitDelay = r.KillGrace + time.Second
+	stdout := &cappedBuffer{limit: r.MaxOutput}
+	stderr := &cappedBuffer{limit: r.MaxOutput}
+	command.Stdout = stdout
+	command.Stderr = stderr
 
+	start := time.Now()
 	err := command.Run()
 
-	res := CommandResult{Stdout: stdout.Bytes(), Stderr: stderr.Bytes(), Error: err}
+	res := CommandResult{
+		Stdout:    stdout.Bytes(),
+		Stderr:    stderr.Bytes(),
+		Error:     err,
+		Truncated: stdout.truncated || stderr.truncated,
+		Usage:     Usage{Wall: time.Since(start)},
+	}
+	if state := command.ProcessState; state != nil {
+		res.Usage.CPU = state.UserTime() + state.SystemTime()
+		res.Usage.MaxRSS = maxRSS(state)
+	}
 	if err != nil {
 		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
+		if errors.Is(nCtx.Err(), context.DeadlineExceeded) {
+			// The process was killed, so its exit status says nothing useful.
+			res.ExitCode = -1
+			res.Error = fmt.Errorf("command %w after %s", ErrTimeout, res.Usage.Wall.Round(100*time.Millisecond))
+		} else if errors.As(err, &exitErr) {
 			if waitStatus, ok := exitErr.Sys().(syscall.WaitStatus); ok {
 				res.ExitCode = waitStatus.ExitStatus()
 			}
-		} else if errors.Is(err, context.DeadlineExceeded) {
+		} else {
+			// The command never started, e.g. the binary is missing.
 			res.ExitCode = -1
-			res.Error = fmt.Errorf("command timed out: %w", err)
 		}
 	} else {
 		res.ExitCode = 0
@@ -62,6 +141,25 @@ func (r ExecRunner) Run(ctx context.Context, cmd string, args ...string) Command
 	return res
 }
 
+// cappedBuffer keeps the first limit bytes written to it and silently drops
+// the rest, so a runaway command cannot exhaust memory.
+type cappedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
 // trimStdout normalises stdout/stderr for logging/diagnostics.
 func trimStdout(data []byte) string {
 	return strings.TrimSpace(string(data))
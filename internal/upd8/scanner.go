@@ -2,14 +2,58 @@ package upd8
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 )
 
+// offlineReason is the Skipped reason of managers that cannot check offline.
+const offlineReason = "requires network"
+
+// detectTimeout bounds a single manager's detection, including its version query.
+const detectTimeout = 15 * time.Second
+
 // Scanner coordinates detection and update queries across managers.
 type Scanner struct {
 	Managers []Manager
 	Runner   CommandRunner
+	// Host labels every result of a scan of a remote machine; see Result.Host.
+	Host string
+	// Connect, if set, is run before detection. When it fails the scan
+	// returns a single result carrying the error instead, named after
+	// Transport (e.g. "ssh" or "docker").
+	Connect   func(context.Context) error
+	Transport string
+	// MaxConcurrency limits how many managers are checked at once; 0 means no limit.
+	MaxConcurrency int
+	// Timeout bounds each manager's check; 0 leaves it to the runner.
+	Timeout time.Duration
+	// Timeouts overrides Timeout for individual managers, keyed by name.
+	Timeouts map[string]time.Duration
+	// Retries is how many more times a check failing with a transient error
+	// (see IsTransient) is attempted. Each attempt gets the full time budget.
+	Retries int
+	// RetryBackoff is the delay before the first retry and doubles after each.
+	RetryBackoff time.Duration
+	// Refresh makes managers that implement Refresher fetch fresh metadata
+	// before checking. RefreshManagers overrides it per manager name.
+	Refresh         bool
+	RefreshManagers map[string]bool
+	// RefreshTimeout bounds each refresh step; 0 leaves it to the runner.
+	RefreshTimeout time.Duration
+	// Offline restricts the scan to local data: managers that implement
+	// OfflineChecker use it, every other manager is skipped, and nothing is
+	// refreshed or retried.
+	Offline bool
+	// Progress, if set, is told when each manager is queued, started and finished.
+	Progress ProgressReporter
+	// Rescan, if set, makes Watch and WatchSchedule scan right away whenever
+	// it receives, in between the regular scans.
+	Rescan <-chan struct{}
+	// NeedsRoot, if set, reports managers whose permission errors mean they
+	// need root (see ScanConfig.NeedsRoot). Such a failure is reported as
+	// skipped, which also covers sudo credentials that expired mid-watch.
+	NeedsRoot func(name string) bool
 }
 
 // Scan detects available managers and fetches their outdated package lists.
@@ -17,6 +61,11 @@ func (s Scanner) Scan(ctx context.Context) []Result {
 	if s.Runner == nil {
 		s.Runner = ExecRunner{}
 	}
+	progress := s.Progress
+	if progress == nil {
+		progress = noProgress{}
+	}
+	defer progress.Done()
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -26,25 +75,59 @@ func (s Scanner) Scan(ctx context.Context) []Result {
 		res Result
 	}
 
+	if s.Connect != nil {
+		if err := s.Connect(ctx); err != nil {
+			res := Result{Host: s.Host, Manager: s.Transport, Err: err}
+			progress.Finished(res)
+			return []Result{res}
+		}
+	}
+
+	detections := s.Detect(ctx)
+
 	output := make([]Result, 0, len(s.Managers))
 	resCh := make(chan resultTuple, len(s.Managers))
 	var wg sync.WaitGroup
 
+	var slots chan struct{}
+	if s.MaxConcurrency > 0 {
+		slots = make(chan struct{}, s.MaxConcurrency)
+	}
+
 	for idx, mgr := range s.Managers {
 		mgr := mgr
 		idx := idx
-		if !mgr.Detect(ctx) {
+		det := detections[idx].Detection
+		if !det.Available {
 			continue
 		}
+		key := resultKey(s.Host, mgr.Name())
+		progress.Queued(key)
 
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			start := time.Now()
-			res := mgr.CheckUpdates(ctx)
-			if res.DurationMs == 0 {
-				res.DurationMs = time.Since(start).Milliseconds()
+			if slots != nil {
+				select {
+				case slots <- struct{}{}:
+					defer func() { <-slots }()
+				case <-ctx.Done():
+					res := Result{Host: s.Host, Manager: mgr.Name(), Err: ctx.Err(), Detection: det}
+					progress.Finished(res)
+					resCh <- resultTuple{idx: idx, res: res}
+					return
+				}
+			}
+			progress.Started(key)
+			refreshed, took, refreshErr := s.refresh(ctx, mgr)
+			res := s.check(ctx, mgr)
+			res.Host = s.Host
+			res.Detection = det
+			res.Refreshed, res.RefreshMs, res.RefreshErr = refreshed, took.Milliseconds(), refreshErr
+			if r, ok := mgr.(Refresher); ok {
+				res.MetadataUpdated = r.MetadataUpdated()
 			}
+			progress.Finished(res)
 			resCh <- resultTuple{idx: idx, res: res}
 		}()
 	}
@@ -66,8 +149,148 @@ func (s Scanner) Scan(ctx context.Context) []Result {
 	return output
 }
 
-// Watch repeatedly scans with a provided interval and invokes the callback with each result batch.
-func (s Scanner) Watch(ctx context.Context, interval time.Duration, cb func([]Result)) {
+// ManagerDetection pairs a manager's name with its detection outcome.
+type ManagerDetection struct {
+	Manager   string    `json:"manager"`
+	Detection Detection `json:"detection"`
+}
+
+// Detect runs every manager's detection concurrently and returns the outcomes
+// in Managers order, including managers that are unavailable. When several
+// managers resolve to the same installation, every one after the first is
+// marked unavailable with DuplicateOf set, so each installation is checked once.
+func (s Scanner) Detect(ctx context.Context) []ManagerDetection {
+	out := make([]ManagerDetection, len(s.Managers))
+	var wg sync.WaitGroup
+	for idx, mgr := range s.Managers {
+		wg.Add(1)
+		go func(idx int, mgr Manager) {
+			defer wg.Done()
+			dctx, cancel := context.WithTimeout(ctx, detectTimeout)
+			defer cancel()
+			out[idx] = ManagerDetection{Manager: mgr.Name(), Detection: mgr.Detect(dctx)}
+		}(idx, mgr)
+	}
+	wg.Wait()
+
+	owners := make(map[string]string)
+	for idx := range out {
+		det := &out[idx].Detection
+		if !det.Available || det.Identity == "" {
+			continue
+		}
+		if owner, ok := owners[det.Identity]; ok {
+			det.Available = false
+			det.DuplicateOf = owner
+			det.Reason = "same installation as " + owner
+			continue
+		}
+		owners[det.Identity] = out[idx].Manager
+	}
+	return out
+}
+
+// check runs a single manager, retrying transient failures with exponential
+// backoff. DurationMs covers all attempts including the waits between them.
+func (s Scanner) check(ctx context.Context, mgr Manager) Result {
+	if s.Offline {
+		oc, ok := mgr.(OfflineChecker)
+		if !ok {
+			return Result{Manager: mgr.Name(), Skipped: offlineReason}
+		}
+		start := time.Now()
+		res := s.attempt(ctx, mgr, oc.CheckOffline)
+		res.Attempts = 1
+		res.DurationMs = time.Since(start).Milliseconds()
+		return res
+	}
+
+	start := time.Now()
+	delay := s.RetryBackoff
+	var usage Usage
+	for attempt := 1; ; attempt++ {
+		res := s.attempt(ctx, mgr, mgr.CheckUpdates)
+		usage = usage.Add(res.Usage)
+		res.Attempts = attempt
+		res.Usage = usage
+		if attempt > s.Retries || !IsTransient(res.Err) || ctx.Err() != nil {
+			res.DurationMs = time.Since(start).Milliseconds()
+			return res
+		}
+
+		select {
+		case <-ctx.Done():
+			res.DurationMs = time.Since(start).Milliseconds()
+			return res
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// attempt runs a single manager under its time budget. A manager that runs
+// out of time is reported with an ErrTimeout error while the others carry on.
+func (s Scanner) attempt(ctx context.Context, mgr Manager, checkFn func(context.Context) Result) Result {
+	timeout := s.timeoutFor(mgr.Name())
+	mctx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		mctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	res := checkFn(mctx)
+
+	if ctx.Err() == nil && errors.Is(mctx.Err(), context.DeadlineExceeded) {
+		res.Items = nil
+		res.Err = timeoutError(mgr.Name(), timeout, res.Err)
+	}
+	if errors.Is(res.Err, ErrPermission) && s.NeedsRoot != nil && s.NeedsRoot(mgr.Name()) {
+		res.Items, res.Err, res.Skipped = nil, nil, needsRootReason
+	}
+	return res
+}
+
+// refresh runs mgr's metadata refresh if it has one and it is enabled. A
+// failed refresh is reported but the check still runs on the old metadata.
+func (s Scanner) refresh(ctx context.Context, mgr Manager) (bool, time.Duration, error) {
+	r, ok := mgr.(Refresher)
+	if !ok || s.Offline {
+		return false, 0, nil
+	}
+	enabled, set := s.RefreshManagers[mgr.Name()]
+	if !set {
+		enabled = s.Refresh
+	}
+	if !enabled {
+		return false, 0, nil
+	}
+
+	rctx := ctx
+	if s.RefreshTimeout > 0 {
+		var cancel context.CancelFunc
+		rctx, cancel = context.WithTimeout(ctx, s.RefreshTimeout)
+		defer cancel()
+	}
+	start := time.Now()
+	err := r.Refresh(rctx)
+	return true, time.Since(start), err
+}
+
+func (s Scanner) timeoutFor(name string) time.Duration {
+	if d, ok := s.Timeouts[name]; ok {
+		return d
+	}
+	return s.Timeout
+}
+
+// Watch repeatedly scans with a provided interval and invokes the callback with
+// each result batch and the time that scan started.
+func (s Scanner) Watch(ctx context.Context, interval time.Duration, cb func(time.Time, []Result)) {
+	watch(ctx, interval, s.Rescan, s.Scan, cb)
+}
+
+func watch(ctx context.Context, interval time.Duration, rescan <-chan struct{}, scan func(context.Context) []Result, cb func(time.Time, []Result)) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
@@ -75,7 +298,8 @@ func (s Scanner) Watch(ctx context.Context, interval time.Duration, cb func([]Re
 	case <-ctx.Done():
 		return
 	default:
-		cb(s.Scan(ctx))
+		start := time.Now()
+		cb(start, scan(ctx))
 	}
 
 	for {
@@ -83,8 +307,70 @@ func (s Scanner) Watch(ctx context.Context, interval time.Duration, cb func([]Re
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			results := s.Scan(ctx)
-			cb(results)
+			start := time.Now()
+			results := scan(ctx)
+			cb(start, results)
+		case <-rescan:
+			start := time.Now()
+			cb(start, scan(ctx))
+			// The next regular scan is a full interval after this one.
+			ticker.Reset(interval)
+		}
+	}
+}
+
+// MultiScanner scans several hosts at once and reports their results as one
+// batch, in Scanners order.
+type MultiScanner struct {
+	Scanners []Scanner
+	// Progress, if set, receives the progress of every host's scan; result
+	// keys tell the hosts apart.
+	Progress ProgressReporter
+	// Rescan is Scanner.Rescan for all hosts.
+	Rescan <-chan struct{}
+}
+
+// Scan runs every scanner concurrently and concatenates their results.
+func (m MultiScanner) Scan(ctx context.Context) []Result {
+	if m.Progress != nil {
+		defer m.Progress.Done()
+	}
+
+	batches := make([][]Result, len(m.Scanners))
+	var wg sync.WaitGroup
+	for i, s := range m.Scanners {
+		if m.Progress != nil {
+			s.Progress = sharedProgress{m.Progress}
 		}
+		wg.Add(1)
+		go func(i int, s Scanner) {
+			defer wg.Done()
+			batches[i] = s.Scan(ctx)
+		}(i, s)
+	}
+	wg.Wait()
+
+	var output []Result
+	for _, batch := range batches {
+		output = append(output, batch...)
 	}
+	return output
+}
+
+// Watch is Scanner.Watch for several hosts.
+func (m MultiScanner) Watch(ctx context.Context, interval time.Duration, cb func(time.Time, []Result)) {
+	watch(ctx, interval, m.Rescan, m.Scan, cb)
 }
+
+// WatchSchedule is Scanner.WatchSchedule for several hosts.
+func (m MultiScanner) WatchSchedule(ctx context.Context, sched Schedule, cb func(time.Time, []Result)) {
+	watchSchedule(ctx, sched, m.Rescan, m.Scan, cb)
+}
+
+// sharedProgress lets several scans report to one display, which the
+// MultiScanner finishes once they are all done.
+type sharedProgress struct {
+	ProgressReporter
+}
+
+func (sharedProgress) Done() {}
@@ -0,0 +1,301 @@
+package upd8
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed five-field cron expression: minute, hour, day of month,
+// month and day of week.
+type Cron struct {
+	expr string
+	// Location is the time zone the fields are interpreted in.
+	Location *time.Location
+
+	minute, hour, dom, month, dow uint64
+	// As in Vixie cron, when both day fields are restricted a day matching
+	// either one is enough; a "*" day field leaves the other in charge.
+	domStar, dowStar bool
+}
+
+// cronMacros are the "@" shorthands cron accepts.
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	cronMonths = []string{"", "JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}
+	cronDays   = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+)
+
+// ParseCron parses expr, such as "0 9 * * MON-FRI" or "@daily", in loc (the
+// local time zone when nil). A "CRON_TZ=Europe/Berlin " or "TZ=..." prefix
+// overrides loc.
+func ParseCron(expr string, loc *time.Location) (*Cron, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	spec := strings.TrimSpace(expr)
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if rest, ok := strings.CutPrefix(spec, prefix); ok {
+			name, fields, _ := strings.Cut(rest, " ")
+			l, err := time.LoadLocation(name)
+			if err != nil {
+				return nil, fmt.Errorf("schedule %q: unknown time zone %q", expr, name)
+			}
+			loc, spec = l, strings.TrimSpace(fields)
+			break
+		}
+	}
+	if macro, ok := cronMacros[strings.ToLower(spec)]; ok {
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule %q: want 5 fields (minute hour day-of-month month day-of-week), got %d", expr, len(fields))
+	}
+	c := &Cron{expr: strings.TrimSpace(expr), Location: loc}
+	var err error
+	parse := func(field string, lo, hi int, names []string) uint64 {
+		if err != nil {
+			return 0
+		}
+		var set uint64
+		set, err = parseCronField(field, lo, hi, names)
+		if err != nil {
+			err = fmt.Errorf("schedule %q: %w", expr, err)
+		}
+		return set
+	}
+	c.minute = parse(fields[0], 0, 59, nil)
+	c.hour = parse(fields[1], 0, 23, nil)
+	c.dom = parse(fields[2], 1, 31, nil)
+	c.month = parse(fields[3], 1, 12, cronMonths)
+	c.dow = parse(fields[4], 0, 7, cronDays)
+	if err != nil {
+		return nil, err
+	}
+	// 7 is another name for Sunday.
+	if c.dow&(1<<7) != 0 {
+		c.dow = c.dow&^(1<<7) | 1
+	}
+	c.domStar = fields[2][0] == '*' || fields[2][0] == '?'
+	c.dowStar = fields[4][0] == '*' || fields[4][0] == '?'
+	return c, nil
+}
+
+// parseCronField turns a comma-separated list of values, ranges and steps
+// into a bit set of the matching values.
+func parseCronField(field string, lo, hi int, names []string) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+			step = n
+		}
+
+		var from, to int
+		switch {
+		case rng == "*" || rng == "?":
+			from, to = lo, hi
+		default:
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if from, err = cronValue(a, names); err != nil {
+				return 0, err
+			}
+			to = from
+			if isRange {
+				if to, err = cronValue(b, names); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// "5/15" means every 15 starting at 5.
+				to = hi
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, lo, hi)
+		}
+		for v := from; v <= to; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func cronValue(s string, names []string) (int, error) {
+	for i, name := range names {
+		if name != "" && strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return n, nil
+}
+
+func (c *Cron) String() string { return c.expr }
+
+// allHours is the hour set of a schedule that runs every hour.
+const allHours = 1<<24 - 1
+
+// Next returns the first matching time after t, in c.Location, or the zero
+// time if nothing matches within five years (e.g. "0 0 30 2 *").
+//
+// Around daylight saving time changes it behaves like cron: times in the
+// hour skipped when clocks go forward run once the clocks have jumped, and
+// times in the hour repeated when they go back run only once, unless the
+// schedule runs every hour anyway.
+func (c *Cron) Next(t time.Time) time.Time {
+	// Minutes and hours are stepped in absolute time so that the repeated
+	// hour is not skipped over by normalizing an ambiguous wall clock time.
+	t = t.In(c.Location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + 5
+	for t.Year() <= limit {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.Location)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.Location)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			next := nextHour(t)
+			if c.skipsMatchingHour(t, next) {
+				return next
+			}
+			t = next
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			// Skip straight to the next matching minute within this hour.
+			if rest := c.minute >> uint(t.Minute()); rest != 0 {
+				t = t.Add(time.Duration(bits.TrailingZeros64(rest)) * time.Minute)
+				continue
+			}
+			next := nextHour(t)
+			if c.skipsMatchingHour(t, next) {
+				return next
+			}
+			t = next
+		case c.hour != allHours && t.Add(-time.Hour).Hour() == t.Hour():
+			// Clocks went back and this hour has already been run once.
+			t = nextHour(t)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// nextHour returns the start of the hour after t's.
+func nextHour(t time.Time) time.Time {
+	return t.Add(-time.Duration(t.Minute()) * time.Minute).Add(time.Hour)
+}
+
+// skipsMatchingHour reports whether clocks going forward between the hours
+// of t and next skipped an hour the schedule would have run in.
+func (c *Cron) skipsMatchingHour(t, next time.Time) bool {
+	if !c.dayMatches(next) {
+		return false
+	}
+	for h := (t.Hour() + 1) % 24; h != next.Hour(); h = (h + 1) % 24 {
+		if c.hour&(1<<uint(h)) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Schedule runs watch mode scans at the times of a cron expression instead
+// of on a fixed interval.
+type Schedule struct {
+	Cron *Cron
+	// Jitter delays each scan by a random duration below it, so that many
+	// machines on the same schedule do not hit the registries at once.
+	Jitter time.Duration
+	// Missed, if set, is called before a scan that runs late because the
+	// machine was asleep or suspended at the scheduled time.
+	Missed func(scheduled time.Time)
+}
+
+// schedulePoll bounds how long the scheduler sleeps at once. Timers follow
+// the monotonic clock, which stops while the machine is suspended; checking
+// the wall clock this often notices a missed run soon after wake-up.
+const schedulePoll = time.Minute
+
+func (s Schedule) next(after time.Time) time.Time {
+	t := s.Cron.Next(after)
+	if t.IsZero() || s.Jitter <= 0 {
+		return t
+	}
+	return t.Add(rand.N(s.Jitter))
+}
+
+// WatchSchedule scans at every time of sched and calls cb with the results.
+// Unlike Watch it does not scan right away. Runs missed while the machine
+// was asleep are made up with a single scan on wake-up.
+func (s Scanner) WatchSchedule(ctx context.Context, sched Schedule, cb func(time.Time, []Result)) {
+	watchSchedule(ctx, sched, s.Rescan, s.Scan, cb)
+}
+
+func watchSchedule(ctx context.Context, sched Schedule, rescan <-chan struct{}, scan func(context.Context) []Result, cb func(time.Time, []Result)) {
+	due := sched.next(time.Now())
+	for !due.IsZero() {
+		// due carries no monotonic reading, so this compares wall clocks.
+		if wait := time.Until(due); wait > 0 {
+			if wait > schedulePoll {
+				wait = schedulePoll
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			case <-rescan:
+				// On-demand scans leave the schedule as it is.
+				timer.Stop()
+				cb(time.Now(), scan(ctx))
+			}
+			continue
+		}
+
+		start := time.Now()
+		if sched.Missed != nil && start.Sub(due) > schedulePoll {
+			sched.Missed(due)
+		}
+		cb(start, scan(ctx))
+		if ctx.Err() != nil {
+			return
+		}
+		// Computing the next run from now collapses any runs missed while
+		// asleep, or during a long scan, into the one just done.
+		due = sched.next(time.Now())
+	}
+}
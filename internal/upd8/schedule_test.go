@@ -0,0 +1,99 @@
+package upd8
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * FOO *",
+		"CRON_TZ=Nowhere/Nothing 0 9 * * *",
+		"@sometimes",
+	} {
+		if _, err := ParseCron(expr, time.UTC); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	at := func(loc *time.Location, s string) time.Time {
+		t.Helper()
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		expr  string
+		loc   *time.Location
+		after string
+		want  []string // consecutive runs, as UTC times
+	}{
+		// 2025-03-14 is a Friday.
+		{"0 9 * * MON-FRI", time.UTC, "2025-03-14 10:00", []string{"2025-03-17 09:00", "2025-03-18 09:00"}},
+		{"*/15 * * * *", time.UTC, "2025-03-14 10:07", []string{"2025-03-14 10:15", "2025-03-14 10:30"}},
+		{"5/20 * * * *", time.UTC, "2025-03-14 10:50", []string{"2025-03-14 11:05", "2025-03-14 11:25"}},
+		{"@daily", time.UTC, "2025-03-14 10:00", []string{"2025-03-15 00:00"}},
+		{"0 0 * * 7", time.UTC, "2025-03-14 10:00", []string{"2025-03-16 00:00", "2025-03-23 00:00"}},
+		{"0 12 1,15 * *", time.UTC, "2025-03-14 10:00", []string{"2025-03-15 12:00", "2025-04-01 12:00"}},
+		// With both day fields restricted either one matching is enough:
+		// the 13th, or any Friday.
+		{"0 0 13 * FRI", time.UTC, "2025-06-01 00:00", []string{"2025-06-06 00:00", "2025-06-13 00:00", "2025-06-20 00:00"}},
+		// A "*" day of week leaves the day of month in charge, and the other
+		// way round.
+		{"0 0 13 * *", time.UTC, "2025-06-01 00:00", []string{"2025-06-13 00:00", "2025-07-13 00:00"}},
+		{"0 0 * * FRI", time.UTC, "2025-06-01 00:00", []string{"2025-06-06 00:00", "2025-06-13 00:00"}},
+		{"0 0 29 2 *", time.UTC, "2025-01-01 00:00", []string{"2028-02-29 00:00"}},
+		{"0 0 30 2 *", time.UTC, "2025-01-01 00:00", nil},
+		{"CRON_TZ=Europe/Berlin 0 9 * * *", time.UTC, "2025-01-10 07:59", []string{"2025-01-10 08:00"}},
+		{"0 9 * * *", berlin, "2025-07-10 06:59", []string{"2025-07-10 07:00"}},
+
+		// Clocks go forward from 02:00 to 03:00 on 2025-03-30: the skipped
+		// 02:30 runs as soon as they have.
+		{"30 2 * * *", berlin, "2025-03-29 03:00", []string{"2025-03-30 01:00", "2025-03-31 00:30"}},
+		{"0 * * * *", berlin, "2025-03-30 00:30", []string{"2025-03-30 00:00", "2025-03-30 01:00", "2025-03-30 02:00"}},
+		// Clocks go back from 03:00 to 02:00 on 2025-10-26: a daily job runs
+		// once, an hourly one in both 02:00 hours.
+		{"30 2 * * *", berlin, "2025-10-25 23:00", []string{"2025-10-26 00:30", "2025-10-27 01:30"}},
+		{"0 * * * *", berlin, "2025-10-26 01:30", []string{"2025-10-26 00:00", "2025-10-26 01:00", "2025-10-26 02:00"}},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr, tt.loc)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		next := at(tt.loc, tt.after)
+		if tt.want == nil {
+			if got := c.Next(next); !got.IsZero() {
+				t.Errorf("%q after %s = %s, want never", tt.expr, tt.after, got)
+			}
+			continue
+		}
+		for _, want := range tt.want {
+			next = c.Next(next)
+			if w := at(time.UTC, want); !next.Equal(w) {
+				t.Errorf("%q after %s: got %s, want %s UTC", tt.expr, tt.after, next.UTC().Format("2006-01-02 15:04"), want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package upd8
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SSHRunner runs commands on a remote host through the local ssh client.
+// Authentication is left to ssh: keys, agents and ~/.ssh/config all apply,
+// but password prompts are disabled so a scan never blocks on input.
+type SSHRunner struct {
+	// Target is the ssh destination, e.g. "deploy@build-01" or a Host alias.
+	Target string
+	// Options are extra arguments for ssh, e.g. {"-p", "2222"}.
+	Options []string
+	// Env holds extra KEY=VALUE pairs set for the remote command on top of
+	// the commandEnv policy.
+	Env []string
+	// ControlDir, if set, holds the sockets ssh uses to share one connection
+	// between the many commands of a scan.
+	ControlDir string
+	// Local runs the ssh client itself and supplies the timeout, kill and
+	// output cap behavior.
+	Local ExecRunner
+}
+
+// NewSSHRunner returns a runner for target that shares connections through
+// a socket directory in the cache directory.
+func NewSSHRunner(target string, cfg ScanConfig) SSHRunner {
+	r := SSHRunner{Target: target, Local: NewExecRunner(cfg)}
+	dir := filepath.Join(CacheDir(), "ssh")
+	if err := os.MkdirAll(dir, 0o700); err == nil {
+		r.ControlDir = dir
+	}
+	return r
+}
+
+// Run executes cmd on the remote host. The remote environment gets the same
+// policy as local commands: format-changing variables are unset and the
+// locale and output settings from commandEnv are applied.
+func (r SSHRunner) Run(ctx context.Context, cmd string, args ...string) CommandResult {
+	sshArgs := []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=10"}
+	if r.ControlDir != "" {
+		sshArgs = append(sshArgs,
+			"-o", "ControlMaster=auto",
+			"-o", "ControlPath="+filepath.Join(r.ControlDir, "%C"),
+			"-o", "ControlPersist=60",
+		)
+	}
+	sshArgs = append(sshArgs, r.Options...)
+	sshArgs = append(sshArgs, "--", r.Target, r.remoteCommand(cmd, args))
+
+	return r.Local.Run(ctx, "ssh", sshArgs...)
+}
+
+// remoteCommand builds the shell command line ssh hands to the remote shell.
+func (r SSHRunner) remoteCommand(cmd string, args []string) string {
+	words := policyCommand(r.Env, cmd, args)
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = shellQuote(w)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// WithEnv returns a copy of r that also sets env on the remote side.
+func (r SSHRunner) WithEnv(env []string) CommandRunner {
+	r.Env = append(append([]string(nil), r.Env...), env...)
+	return r
+}
+
+var sshHints = Hints{
+	ErrPermission: "check that `ssh -o BatchMode=yes <host> true` works without a password prompt",
+	ErrNetwork:    "the host could not be reached; check the name, port and VPN",
+}
+
+// Ping checks that the host accepts a non-interactive connection.
+func (r SSHRunner) Ping(ctx context.Context) error {
+	res := r.Run(ctx, "true")
+	if res.Error != nil {
+		return commandError("ssh "+r.Target, res, sshHints)
+	}
+	return nil
+}
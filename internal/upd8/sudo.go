@@ -0,0 +1,174 @@
+package upd8
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// sudoTools are the supported privilege escalation commands in order of
+// preference for "auto".
+var sudoTools = []string{"sudo", "doas"}
+
+// needsRootReason is the Skipped reason of root-only managers that upd8
+// cannot elevate.
+const needsRootReason = "needs root (rerun with --sudo)"
+
+// SudoRunner runs commands as root through sudo or doas. It never prompts:
+// commands fail instead when a password would be needed.
+type SudoRunner struct {
+	// Tool is "sudo" or "doas".
+	Tool  string
+	Inner CommandRunner
+	// Env holds extra KEY=VALUE pairs. sudo resets the environment, so they
+	// and the commandEnv policy are applied after elevation.
+	Env []string
+}
+
+func (r SudoRunner) Run(ctx context.Context, cmd string, args ...string) CommandResult {
+	sudoArgs := append([]string{"-n"}, policyCommand(r.Env, cmd, args)...)
+	return safeRunner(r.Inner).Run(ctx, r.Tool, sudoArgs...)
+}
+
+// WithEnv returns a copy of r that also sets env for the elevated command.
+func (r SudoRunner) WithEnv(env []string) CommandRunner {
+	r.Env = append(append([]string(nil), r.Env...), env...)
+	return r
+}
+
+// PrepareSudo sets up host.Sudo for tool ("sudo", "doas" or "auto"). On the
+// local machine with interactive set, the user is asked for a password once
+// so that later commands run without prompting. Hosts where upd8 already runs
+// as root need no tool. When elevation is not possible host is returned
+// unchanged with the reason.
+func PrepareSudo(ctx context.Context, host Host, tool string, interactive bool) (Host, error) {
+	probe := host.probe()
+	if tool == "" || probe.IsRoot() {
+		return host, nil
+	}
+
+	candidates := []string{tool}
+	if tool == "auto" {
+		candidates = sudoTools
+	}
+	resolved := ""
+	for _, c := range candidates {
+		if _, err := probe.LookPath(c); err == nil {
+			resolved = c
+			break
+		}
+	}
+	if resolved == "" {
+		return host, errors.New(strings.Join(candidates, " or ") + " not found")
+	}
+
+	if interactive && host.Name == "" {
+		// sudo -v only refreshes the cached credentials; doas has no such
+		// mode and relies on its "persist" option instead.
+		args := []string{"-v"}
+		if resolved == "doas" {
+			args = []string{"true"}
+		}
+		prompt := exec.CommandContext(ctx, resolved, args...)
+		prompt.Stdin, prompt.Stdout, prompt.Stderr = os.Stdin, os.Stderr, os.Stderr
+		if err := prompt.Run(); err != nil {
+			return host, errors.New(resolved + " was not authorized")
+		}
+	}
+
+	res := host.runner().Run(ctx, resolved, "-n", "true")
+	if res.Error != nil {
+		detail := lastLine(string(res.Stderr))
+		if detail == "" {
+			detail = res.Error.Error()
+		}
+		return host, errors.New(detail)
+	}
+	host.Sudo = resolved
+	return host, nil
+}
+
+// rootDefaults lists managers whose checks need root on some systems, such
+// as snap behind a locked-down snapd socket or flatpak's system installation.
+// Unless scan.requires_root names them they run as the user, and only a
+// permission error is taken to mean they need root; see deniedRunner.
+var rootDefaults = map[string]bool{"snap": true, "flatpak": true}
+
+// configuredRoot returns the scan.requires_root setting for the named
+// manager. Settings for a base name such as "pip" also cover
+// "pip:pyenv-3.12.1".
+func configuredRoot(cfg ScanConfig, name string) (value, set bool) {
+	if v, ok := cfg.RequiresRoot[name]; ok {
+		return v, true
+	}
+	base, _, _ := strings.Cut(name, ":")
+	value, set = cfg.RequiresRoot[base]
+	return value, set
+}
+
+// needsRoot reports whether the named manager is configured as root-only.
+func needsRoot(cfg ScanConfig, name string) bool {
+	v, _ := configuredRoot(cfg, name)
+	return v
+}
+
+// mayNeedRoot reports whether the named manager is one of rootDefaults and
+// not configured either way.
+func mayNeedRoot(cfg ScanConfig, name string) bool {
+	if _, set := configuredRoot(cfg, name); set {
+		return false
+	}
+	base, _, _ := strings.Cut(name, ":")
+	return rootDefaults[base]
+}
+
+// NeedsRoot reports whether a permission error from the named manager means
+// that it needs root, because it is configured as root-only or is one of the
+// built-in defaults. See Scanner.NeedsRoot.
+func (c ScanConfig) NeedsRoot(name string) bool {
+	return needsRoot(c, name) || mayNeedRoot(c, name)
+}
+
+// deniedRunner runs commands as the user and retries the ones refused for
+// lack of permission through elevated.
+type deniedRunner struct {
+	plain, elevated CommandRunner
+}
+
+func (r deniedRunner) Run(ctx context.Context, cmd string, args ...string) CommandResult {
+	res := r.plain.Run(ctx, cmd, args...)
+	if res.ExitCode != 0 && classify(res, strings.ToLower(string(res.Stderr))) == ErrPermission {
+		return r.elevated.Run(ctx, cmd, args...)
+	}
+	return res
+}
+
+// WithEnv returns a copy of r that sets env both ways.
+func (r deniedRunner) WithEnv(env []string) CommandRunner {
+	for _, inner := range []*CommandRunner{&r.plain, &r.elevated} {
+		if er, ok := (*inner).(EnvRunner); ok {
+			*inner = er.WithEnv(env)
+		}
+	}
+	return r
+}
+
+// rootOnly stands in for a root-only manager that cannot be elevated. It is
+// still detected but reports a skipped check instead of a permission error.
+type rootOnly struct {
+	Manager
+}
+
+func (m rootOnly) CheckUpdates(context.Context) Result {
+	return Result{Manager: m.Name(), Skipped: needsRootReason}
+}
+
+func (m rootOnly) CheckOffline(ctx context.Context) Result {
+	return m.CheckUpdates(ctx)
+}
+
+func (m rootOnly) Upgrade(context.Context, []string) error {
+	return errors.New(m.Name() + " " + needsRootReason)
+}
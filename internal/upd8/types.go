@@ -2,28 +2,118 @@ package upd8
 
 import (
 	"context"
+	"time"
 )
 
 // Item describes a single outdated package.
 type Item struct {
-	Name        string
-	Current     string
-	Latest      string
-	Description string
+	Name        string `json:"name"`
+	Current     string `json:"current,omitempty"`
+	Latest      string `json:"latest,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// OutdatedSince and ReleasesBehind are derived from scan history by AnnotateAges.
+	OutdatedSince  *time.Time `json:"outdated_since,omitempty"`
+	OutdatedDays   int        `json:"outdated_days,omitempty"`
+	ReleasesBehind int        `json:"releases_behind,omitempty"`
 }
 
 // Result captures the outcome of running an update check for a package manager.
 type Result struct {
+	// Host names the remote machine the result came from; empty for local.
+	Host          string
 	Manager       string
 	Items         []Item
 	UpdateCommand string
 	Err           error
 	DurationMs    int64
+	// Attempts is how many times the check ran, counting retries.
+	Attempts int
+	// Usage is the resources used by the manager's commands across attempts.
+	Usage Usage
+	// MetadataUpdated is when a Refresher's metadata was last fetched.
+	MetadataUpdated time.Time
+	// Refreshed is set when the metadata was refreshed before this check;
+	// RefreshErr holds the reason if that failed.
+	Refreshed  bool
+	RefreshErr error
+	RefreshMs  int64
+	// Skipped gives the reason a manager was not checked, e.g. because it
+	// needs the network in offline mode. Skipped results carry no items.
+	Skipped string
+	// CachedAt is set when the result was served from the result cache.
+	CachedAt time.Time
+	// Detection describes the installation that produced this result.
+	Detection Detection
+}
+
+// Detection describes whether and how a package manager is installed.
+type Detection struct {
+	Available bool   `json:"available"`
+	Path      string `json:"path,omitempty"`
+	Version   string `json:"version,omitempty"`
+	// Scope is "user" for installs under the home directory, otherwise "system".
+	Scope string `json:"scope,omitempty"`
+	// Reason explains why the manager is unavailable.
+	Reason string `json:"reason,omitempty"`
+	// Identity names the installation a manager operates on. Managers with the
+	// same non-empty Identity (e.g. pip and pip3 sharing one site-packages)
+	// are merged by Scanner.Detect.
+	Identity string `json:"identity,omitempty"`
+	// Label distinguishes the installation in reports, e.g. "python 3.12".
+	Label string `json:"label,omitempty"`
+	// Interpreter is the runtime backing the manager, when known.
+	Interpreter string `json:"interpreter,omitempty"`
+	// DuplicateOf names the earlier manager this one was merged into.
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+}
+
+// DisplayName returns the manager name with its installation label, if any.
+func (r Result) DisplayName() string {
+	name := r.Key()
+	if r.Detection.Label == "" {
+		return name
+	}
+	return name + " (" + r.Detection.Label + ")"
+}
+
+// Key identifies the manager across scans and hosts, e.g. "build1/brew".
+func (r Result) Key() string {
+	return resultKey(r.Host, r.Manager)
+}
+
+func resultKey(host, manager string) string {
+	if host == "" {
+		return manager
+	}
+	return host + "/" + manager
 }
 
 // Manager defines the capabilities of a package manager implementation.
 type Manager interface {
 	Name() string
-	Detect(ctx context.Context) bool
+	Detect(ctx context.Context) Detection
 	CheckUpdates(ctx context.Context) Result
 }
+
+// OfflineChecker is implemented by managers that can report outdated
+// packages from local data alone, without any network access.
+type OfflineChecker interface {
+	CheckOffline(ctx context.Context) Result
+}
+
+// Refresher is implemented by managers that check against local package
+// metadata, which goes stale unless it is fetched again.
+type Refresher interface {
+	// Refresh fetches the metadata, e.g. `brew update`.
+	Refresh(ctx context.Context) error
+	// MetadataUpdated reports when the metadata was last fetched, or the zero
+	// time if that is unknown.
+	MetadataUpdated() time.Time
+}
+
+// Upgrader is implemented by managers that can upgrade individual packages.
+type Upgrader interface {
+	// Upgrade upgrades the named packages to their latest versions.
+	Upgrade(ctx context.Context, pkgs []string) error
+}
@@ -0,0 +1,43 @@
+package upd8
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// upgradeTimeout bounds an upgrade started without a deadline of its own;
+// upgrades download and build far more than checks do.
+const upgradeTimeout = 30 * time.Minute
+
+// Upgrade upgrades pkgs with the manager whose result key is key.
+func (s Scanner) Upgrade(ctx context.Context, key string, pkgs []string) error {
+	for _, m := range s.Managers {
+		if resultKey(s.Host, m.Name()) != key {
+			continue
+		}
+		u, ok := m.(Upgrader)
+		if !ok {
+			return fmt.Errorf("%s cannot upgrade individual packages", key)
+		}
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, upgradeTimeout)
+			defer cancel()
+		}
+		return u.Upgrade(ctx, pkgs)
+	}
+	return fmt.Errorf("no manager %q", key)
+}
+
+// Upgrade is Scanner.Upgrade for several hosts.
+func (m MultiScanner) Upgrade(ctx context.Context, key string, pkgs []string) error {
+	for _, s := range m.Scanners {
+		for _, mgr := range s.Managers {
+			if resultKey(s.Host, mgr.Name()) == key {
+				return s.Upgrade(ctx, key, pkgs)
+			}
+		}
+	}
+	return fmt.Errorf("no manager %q", key)
+}
@@ -1,21 +1,76 @@
 package upd8
 
 import (
-	"context"
-	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 )
 
-func lookupBinary(name string) bool {
-	_, err := exec.LookPath(name)
+func lookupBinary(p Prober, name string) bool {
+	_, err := p.LookPath(name)
 	return err == nil
 }
 
-// detectViaCommands checks that all commands/binaries exist.
-func detectViaCommands(ctx context.Context, binaries ...string) bool {
-	for _, bin := range binaries {
-		if !lookupBinary(bin) {
-			return false
+var versionRegexp = regexp.MustCompile(`\d+(?:\.\d+)+[^\s,)]*`)
+
+// firstVersion extracts the first dotted version number from command output.
+func firstVersion(output string) string {
+	return versionRegexp.FindString(output)
+}
+
+// installScope classifies a binary as a per-user or system-wide install.
+func installScope(p Prober, path string) string {
+	path = p.EvalSymlinks(path)
+	home := p.HomeDir()
+	if home != "" && strings.HasPrefix(path, home+"/") {
+		return "user"
+	}
+	return "system"
+}
+
+// newestModTime returns the latest modification time among paths that exist.
+func newestModTime(p Prober, paths ...string) time.Time {
+	var newest time.Time
+	for _, path := range paths {
+		if st, ok := p.Stat(path); ok && st.ModTime.After(newest) {
+			newest = st.ModTime
+		}
+	}
+	return newest
+}
+
+// oldestModTime returns the earliest modification time among paths that exist.
+func oldestModTime(p Prober, paths ...string) time.Time {
+	var oldest time.Time
+	for _, path := range paths {
+		if st, ok := p.Stat(path); ok && (oldest.IsZero() || st.ModTime.Before(oldest)) {
+			oldest = st.ModTime
 		}
 	}
-	return true
+	return oldest
+}
+
+func isFile(p Prober, path string) bool {
+	st, ok := p.Stat(path)
+	return ok && !st.IsDir
+}
+
+func dirExists(p Prober, path string) bool {
+	st, ok := p.Stat(path)
+	return ok && st.IsDir
+}
+
+// expandHome replaces a leading "~" with the user's home directory.
+func expandHome(path, home string) string {
+	if home == "" {
+		return path
+	}
+	if path == "~" {
+		return home
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return filepath.Join(home, rest)
+	}
+	return path
 }